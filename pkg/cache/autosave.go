@@ -0,0 +1,46 @@
+package cache
+
+import (
+    "fmt"
+    "sync"
+    "time"
+)
+
+// AutoSave saves the cache to name with SaveFile every interval until the
+// returned function is called. Failed saves are passed to the handler set
+// with WithErrorHandler, if any.
+func (c *cache) AutoSave(name string, interval time.Duration) (stop func()) {
+    if interval <= 0 {
+        return func() {}
+    }
+    done := make(chan struct{})
+    exited := make(chan struct{})
+    go func() {
+        defer close(exited)
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-ticker.C:
+                if err := c.SaveFile(name); err != nil {
+                    c.handleError(fmt.Errorf("auto-saving cache to %s: %w", name, err))
+                }
+            case <-done:
+                return
+            }
+        }
+    }()
+    var once sync.Once
+    return func() {
+        once.Do(func() {
+            close(done)
+        })
+        <-exited
+    }
+}
+
+func (c *cache) handleError(err error) {
+    if c.onError != nil {
+        c.onError(err)
+    }
+}
@@ -0,0 +1,247 @@
+package cache
+
+import "time"
+
+// SetMany stores each of items for d under one lock. Items whose keys fail
+// the key checks are skipped, and the errors passed to the WithErrorHandler
+// handler.
+func (c *cache) SetMany(items map[string]interface{}, d time.Duration) {
+    errs := checkKeys(c, items)
+    c.handleKeyErrors(errs)
+    c.setMany(items, d, errs, nil)
+}
+
+// SetManyChecked is like SetMany, but stores none of items if any of their
+// keys fail the key checks, returning the error for one of them instead.
+func (c *cache) SetManyChecked(items map[string]interface{}, d time.Duration) error {
+    for _, err := range checkKeys(c, items) {
+        return err
+    }
+    c.setMany(items, d, nil, nil)
+    return nil
+}
+
+// setMany stores items for d, except those under the keys in skip, and
+// records the live values they replace in old if it isn't nil.
+func (c *cache) setMany(items map[string]interface{}, d time.Duration, skip map[string]error, old map[string]interface{}) {
+    var evicted []keyAndValue
+    c.mu.Lock()
+    for k, x := range items {
+        if _, ok := skip[k]; ok {
+            continue
+        }
+        if old != nil {
+            if item, found := c.items.Get(k); found && !c.readExpired(item) && item.Object != cachedMiss {
+                old[k] = item.Object
+            }
+        }
+        evicted = append(evicted, c.set(k, x, d)...)
+    }
+    c.mu.Unlock()
+    c.notifyEvicted(evicted)
+    if c.writeSet != nil {
+        for k, x := range items {
+            if _, ok := skip[k]; !ok {
+                c.mirrorSet(k, x, d)
+            }
+        }
+    }
+}
+
+// SetManyReturningOld is like SetMany, but returns the values it replaced,
+// keyed by key, for the items that were live. The replaced items are still
+// passed to the eviction callbacks as EvictReplaced.
+func (c *cache) SetManyReturningOld(items map[string]interface{}, d time.Duration) map[string]interface{} {
+    errs := checkKeys(c, items)
+    c.handleKeyErrors(errs)
+    old := make(map[string]interface{})
+    c.setMany(items, d, errs, old)
+    return old
+}
+
+// SetManyWithExpiration stores each of items under one lock, expiring at its
+// own Expiration rather than the default. As with SetWithDeadline, an item
+// whose Expiration has already passed is stored expired: it replaces any item
+// under its key, Get doesn't find it and the janitor deletes it. Items with no
+// Created time are given the current time. Items whose keys fail the key
+// checks are skipped, as by SetMany.
+func (c *cache) SetManyWithExpiration(items map[string]Item) {
+    errs := checkKeys(c, items)
+    c.handleKeyErrors(errs)
+    var evicted []keyAndValue
+    now := c.now()
+    c.mu.Lock()
+    for k, item := range items {
+        if _, ok := errs[k]; ok {
+            continue
+        }
+        if item.Created == 0 {
+            item.Created = now
+        }
+        evicted = append(evicted, c.insert(k, item)...)
+    }
+    c.mu.Unlock()
+    c.notifyEvicted(evicted)
+    if c.writeSet != nil {
+        for k, item := range items {
+            if _, ok := errs[k]; !ok {
+                c.mirrorItem(k, item)
+            }
+        }
+    }
+}
+
+func (c *cache) GetMany(keys []string) map[string]interface{} {
+    items := c.getMany(keys)
+    m := make(map[string]interface{}, len(items))
+    for k, item := range items {
+        m[k] = item.Object
+    }
+    return m
+}
+
+// ValueWithExpiration is a value returned by GetManyWithExpiration, with the
+// time it expires, or the zero Time if it doesn't.
+type ValueWithExpiration struct {
+    Value      interface{}
+    Expiration time.Time
+}
+
+// GetManyWithExpiration is like GetMany, but also returns when each value
+// expires.
+func (c *cache) GetManyWithExpiration(keys []string) map[string]ValueWithExpiration {
+    items := c.getMany(keys)
+    m := make(map[string]ValueWithExpiration, len(items))
+    for k, item := range items {
+        v := ValueWithExpiration{Value: item.Object}
+        if item.Expiration > 0 {
+            v.Expiration = time.Unix(0, item.Expiration)
+        }
+        m[k] = v
+    }
+    return m
+}
+
+// getMany returns the live items under keys, reading them all under one
+// lock.
+func (c *cache) getMany(keys []string) map[string]Item {
+    m := make(map[string]Item, len(keys))
+    var idle, reads []string
+    // Recording every read needs the write lock
+    if c.recordsReads() {
+        c.mu.Lock()
+    } else {
+        c.mu.RLock()
+    }
+    for _, k := range keys {
+        item, found := c.items.Get(k)
+        if !found || c.readExpired(item) || item.Object == cachedMiss {
+            c.stats.miss()
+            continue
+        }
+        c.stats.hit()
+        if c.recordsReads() {
+            item = c.access(k, item)
+        } else if item.Idle > 0 {
+            idle = append(idle, k)
+        } else if c.evictor != nil {
+            reads = append(reads, k)
+        }
+        m[k] = item
+    }
+    if c.recordsReads() {
+        c.mu.Unlock()
+        return m
+    }
+    c.mu.RUnlock()
+    if len(reads) > 0 {
+        c.bufferRead(reads...)
+    }
+    if len(idle) > 0 {
+        c.mu.Lock()
+        for _, k := range idle {
+            if item, found := c.items.Get(k); found && !c.readExpired(item) {
+                m[k] = c.access(k, item)
+            }
+        }
+        c.mu.Unlock()
+    }
+    return m
+}
+
+func (c *cache) DeleteMany(keys []string) {
+    var evicted []keyAndValue
+    c.mu.Lock()
+    for _, k := range keys {
+        v, found := c.delete(k)
+        if found {
+            c.stats.evicted()
+            evicted = append(evicted, keyAndValue{k, v, EvictDeleted})
+        }
+    }
+    c.mu.Unlock()
+    c.notifyEvicted(evicted)
+    // Like Delete, whether or not the cache held them
+    for _, k := range keys {
+        c.mirrorDelete(k)
+    }
+}
+
+// IncrementMany adds each delta to the int64 or int stored under its key,
+// under a single lock, and returns the new values. A key with no live item is
+// set to its delta with the default expiration, unless the key fails the key
+// checks, whose errors are passed to the WithErrorHandler handler. Keys
+// holding values of other types, and those not set, are left alone and
+// missing from the result.
+func (c *cache) IncrementMany(deltas map[string]int64) map[string]int64 {
+    // Only needed for the keys created, but checked before taking the lock
+    errs := checkKeys(c, deltas)
+    m := make(map[string]int64, len(deltas))
+    var evicted []keyAndValue
+    var keyErrs []error
+    var written map[string]Item
+    if c.writeSet != nil {
+        written = make(map[string]Item, len(deltas))
+    }
+    c.mu.Lock()
+    for k, n := range deltas {
+        item, found := c.items.Get(k)
+        if !found || c.readExpired(item) || item.Object == cachedMiss {
+            if err, ok := errs[k]; ok {
+                keyErrs = append(keyErrs, err)
+                continue
+            }
+            item = c.newItem(n, DefaultExpiration)
+            evicted = append(evicted, c.insert(k, item)...)
+            m[k] = n
+            if written != nil {
+                written[k] = item
+            }
+            continue
+        }
+        switch v := item.Object.(type) {
+        case int64:
+            item.Object = v + n
+            m[k] = v + n
+        case int:
+            item.Object = v + int(n)
+            m[k] = int64(v + int(n))
+        default:
+            continue
+        }
+        c.items.Set(k, item)
+        c.changed(k, item.Object)
+        if written != nil {
+            written[k] = item
+        }
+    }
+    c.mu.Unlock()
+    for _, err := range keyErrs {
+        c.handleError(err)
+    }
+    c.notifyEvicted(evicted)
+    for k, item := range written {
+        c.mirrorItem(k, item)
+    }
+    return m
+}
@@ -0,0 +1,98 @@
+package cache
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/binary"
+    "fmt"
+    "io"
+    "time"
+)
+
+// BytesCache is a Cache of []byte values that saves them in a simple binary
+// format instead of gob.
+type BytesCache struct {
+    *Cache
+}
+
+func NewBytesCache(defaultExpiration, cleanupInterval time.Duration, opts ...Option) *BytesCache {
+    return &BytesCache{New(defaultExpiration, cleanupInterval, opts...)}
+}
+
+func (b *BytesCache) Set(k string, v []byte, d time.Duration) {
+    b.Cache.Set(k, v, d)
+}
+
+func (b *BytesCache) Get(k string) ([]byte, bool) {
+    return b.GetBytes(k)
+}
+
+// Save writes the cache's unexpired []byte items to w, each as its key, its
+// Expiration and its value, with the key and value preceded by their lengths
+// as uvarints and the Expiration written as a varint. Items with values of
+// other types are left out.
+func (b *BytesCache) Save(w io.Writer) error {
+    bw := bufio.NewWriter(w)
+    var buf [binary.MaxVarintLen64]byte
+    for k, item := range b.Items() {
+        v, ok := item.Object.([]byte)
+        if !ok {
+            continue
+        }
+        bw.Write(buf[:binary.PutUvarint(buf[:], uint64(len(k)))])
+        bw.WriteString(k)
+        bw.Write(buf[:binary.PutVarint(buf[:], item.Expiration)])
+        bw.Write(buf[:binary.PutUvarint(buf[:], uint64(len(v)))])
+        if _, err := bw.Write(v); err != nil {
+            return err
+        }
+    }
+    return bw.Flush()
+}
+
+// Load adds the items written by Save, keeping any live items already in the
+// cache.
+func (b *BytesCache) Load(r io.Reader) error {
+    br := bufio.NewReader(r)
+    items := map[string]Item{}
+    for {
+        k, err := readBytes(br)
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return err
+        }
+        e, err := binary.ReadVarint(br)
+        if err != nil {
+            return unexpectedEOF(err)
+        }
+        v, err := readBytes(br)
+        if err != nil {
+            return unexpectedEOF(err)
+        }
+        items[string(k)] = Item{Object: v, Expiration: e}
+    }
+    b.loadItems(items)
+    return nil
+}
+
+func readBytes(br *bufio.Reader) ([]byte, error) {
+    n, err := binary.ReadUvarint(br)
+    if err != nil {
+        return nil, err
+    }
+    // Grow as the data arrives rather than trust a corrupt length
+    var buf bytes.Buffer
+    if _, err := io.CopyN(&buf, br, int64(n)); err != nil {
+        return nil, unexpectedEOF(err)
+    }
+    return buf.Bytes(), nil
+}
+
+func unexpectedEOF(err error) error {
+    if err == io.EOF {
+        return fmt.Errorf("reading bytes cache: %w", io.ErrUnexpectedEOF)
+    }
+    return err
+}
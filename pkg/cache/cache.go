@@ -4,14 +4,32 @@ import (
     "encoding/gob"
     "fmt"
     "io"
+    "math/rand"
     "os"
+    "path/filepath"
+    "reflect"
+    "sort"
     "sync"
+    "sync/atomic"
     "time"
 )
 
 type Item struct {
     Object     interface{}
     Expiration int64
+    Idle       time.Duration
+    // Created and LastAccess are Unix nanosecond times, and zero for items
+    // loaded from dumps that predate them. LastAccess is only kept by caches
+    // created WithAccessTracking.
+    Created    int64
+    LastAccess int64
+    // AccessCount is the number of times the item has been read, kept like
+    // LastAccess by caches created WithAccessTracking.
+    AccessCount int64
+    // BaseTTL is the duration the item was stored for, from which a cache
+    // created WithAdaptiveTTL computes its expiration as it is read. It is
+    // zero for items that don't adapt.
+    BaseTTL time.Duration
 }
 
 func (item Item) Expired() bool {
@@ -24,6 +42,9 @@ func (item Item) Expired() bool {
 const (
     NoExpiration      time.Duration = -1
     DefaultExpiration time.Duration = 0
+    // KeepExpiration leaves an existing item's expiration as it is, for the
+    // methods that accept it.
+    KeepExpiration time.Duration = -2
 )
 
 type Cache struct {
@@ -31,119 +52,800 @@ type Cache struct {
 }
 
 type cache struct {
-    defaultExpiration time.Duration
-    items             map[string]Item
-    mu                sync.RWMutex
-    onEvicted         func(string, interface{})
-    janitor           *janitor
+    defaultExpiration  atomic.Int64
+    cleanupInterval    time.Duration
+    clock              Clock
+    items              Store
+    mu                 sync.RWMutex
+    callbacks          atomic.Pointer[evictCallbacks]
+    subMu              sync.Mutex
+    subscribers        map[chan EvictEvent]struct{}
+    watchers           map[string]map[chan interface{}]struct{}
+    janitor            *janitor
+    janitorMu          sync.Mutex
+    loadMu             sync.Mutex
+    loads              map[string]*loadCall
+    loadSem            chan struct{}
+    stats              *stats
+    maxItems           int
+    policy             EvictionPolicy
+    evictor            evictor
+    sizer              Sizer
+    maxBytes           int64
+    bytes              int64
+    sizes              map[string]int64
+    expirations        *expirations
+    onError            func(error)
+    trackAccess        bool
+    jitter             float64
+    writeSet           func(string, interface{}, time.Duration) error
+    writeDelete        func(string) error
+    maxKeyLength       int
+    keyValidator       func(string) error
+    refreshWindow      time.Duration
+    refresh            func(string) (interface{}, time.Duration, error)
+    refreshMu          sync.Mutex
+    refreshing         map[string]struct{}
+    evictionLog        *evictionLog
+    count              atomic.Int64
+    readMu             sync.Mutex
+    reads              []string
+    maxPendingExpired  int
+    pendingChecks      atomic.Uint32
+    cleanupBudget      time.Duration
+    adaptiveTTL        func(accessCount int64, baseTTL time.Duration) time.Duration
+    janitorExpiresOnly bool
+    stampReads         bool
+    precise            bool
+    timer              *time.Timer
+    timerAt            int64
 }
 
 func (c *cache) Set(k string, x interface{}, d time.Duration) {
-    var e int64
-    if d == DefaultExpiration {
-        d = c.defaultExpiration
+    if c.stats.recordsLatency() {
+        defer c.stats.latency.set.observe(time.Now())
     }
-    if d > 0 {
-        e = time.Now().Add(d).UnixNano()
+    if err := c.SetThrough(k, x, d); err != nil {
+        c.handleError(err)
+    }
+}
+
+// SetChecked is like Set, but returns an error instead of storing x if k is
+// longer than the WithMaxKeyLength limit or fails the WithKeyValidator check.
+// Set passes that error to the WithErrorHandler handler.
+func (c *cache) SetChecked(k string, x interface{}, d time.Duration) error {
+    if err := c.checkKey(k); err != nil {
+        return err
+    }
+    c.Set(k, x, d)
+    return nil
+}
+
+// checkKeys runs checkKey on each of m's keys, returning the errors for those
+// that fail by key, or nil if none do.
+func checkKeys[V any](c *cache, m map[string]V) map[string]error {
+    if c.maxKeyLength <= 0 && c.keyValidator == nil {
+        return nil
+    }
+    var errs map[string]error
+    for k := range m {
+        if err := c.checkKey(k); err != nil {
+            if errs == nil {
+                errs = make(map[string]error)
+            }
+            errs[k] = err
+        }
+    }
+    return errs
+}
+
+// handleKeyErrors passes the errors from checkKeys to the error handler.
+func (c *cache) handleKeyErrors(errs map[string]error) {
+    for _, err := range errs {
+        c.handleError(err)
+    }
+}
+
+func (c *cache) checkKey(k string) error {
+    if c.maxKeyLength > 0 && len(k) > c.maxKeyLength {
+        return fmt.Errorf("key of %d bytes is longer than the limit of %d", len(k), c.maxKeyLength)
+    }
+    if c.keyValidator != nil {
+        return c.keyValidator(k)
+    }
+    return nil
+}
+
+// SetThrough is like Set, but returns the error from the write-through hook
+// set WithWriteThrough, which is called once the item is in the cache.
+func (c *cache) SetThrough(k string, x interface{}, d time.Duration) error {
+    if err := c.setLocally(k, x, d); err != nil {
+        return err
+    }
+    return c.writeThrough(k, x, d)
+}
+
+// setLocally is Set without the write-through hook, for values that came
+// from the backing store or aren't the caller's, such as cached misses. It
+// returns the error from the key checks.
+func (c *cache) setLocally(k string, x interface{}, d time.Duration) error {
+    if err := c.checkKey(k); err != nil {
+        return err
     }
+    item := c.newItem(x, d)
     c.mu.Lock()
-    c.items[k] = Item{
+    evicted := c.insert(k, item)
+    c.mu.Unlock()
+    c.notifyEvicted(evicted)
+    if c.maxPendingExpired > 0 {
+        c.deleteExpiredIfPending()
+    }
+    return nil
+}
+
+func (c *cache) set(k string, x interface{}, d time.Duration) []keyAndValue {
+    return c.insert(k, c.newItem(x, d))
+}
+
+// newItem returns an item holding x that expires after d, which is
+// interpreted as in Set.
+func (c *cache) newItem(x interface{}, d time.Duration) Item {
+    now := c.now()
+    item := Item{
         Object:     x,
-        Expiration: e,
+        Expiration: c.expiration(d),
+        Created:    now,
+    }
+    if c.adaptiveTTL != nil && item.Expiration > 0 {
+        item.BaseTTL = time.Duration(item.Expiration - now)
+    }
+    return item
+}
+
+// SetWithIdle stores x so that it expires once it goes unread for idle; each
+// Get moves its expiration to idle from then. An idle of zero or less means the
+// item never expires.
+func (c *cache) SetWithIdle(k string, x interface{}, idle time.Duration) {
+    if err := c.checkKey(k); err != nil {
+        c.handleError(err)
+        return
+    }
+    item := Item{
+        Object:  x,
+        Idle:    idle,
+        Created: c.now(),
     }
+    if idle > 0 {
+        item.Expiration = c.clock.Now().Add(idle).UnixNano()
+    }
+    c.mu.Lock()
+    evicted := c.insert(k, item)
     c.mu.Unlock()
+    c.notifyEvicted(evicted)
+    c.mirrorItem(k, item)
 }
 
-func (c *cache) set(k string, x interface{}, d time.Duration) {
-    var e int64
+// SetWithDeadline stores x so that it expires at deadline. An item stored
+// with a deadline that has already passed is expired straight away: Get
+// doesn't find it and the janitor deletes it.
+func (c *cache) SetWithDeadline(k string, x interface{}, deadline time.Time) {
+    if err := c.checkKey(k); err != nil {
+        c.handleError(err)
+        return
+    }
+    item := Item{
+        Object:     x,
+        Expiration: deadlineExpiration(deadline),
+        Created:    c.now(),
+    }
+    c.mu.Lock()
+    evicted := c.insert(k, item)
+    c.mu.Unlock()
+    c.notifyEvicted(evicted)
+    c.mirrorItem(k, item)
+}
+
+// deadlineExpiration returns the Expiration for deadline, which is never zero
+// or less, as that would mean the item doesn't expire.
+func deadlineExpiration(deadline time.Time) int64 {
+    if e := deadline.UnixNano(); e > 0 {
+        return e
+    }
+    return 1
+}
+
+// expiration returns the Expiration for an item stored now with duration d.
+func (c *cache) expiration(d time.Duration) int64 {
     if d == DefaultExpiration {
-        d = c.defaultExpiration
+        d = time.Duration(c.defaultExpiration.Load())
     }
     if d > 0 {
-        e = time.Now().Add(d).UnixNano()
+        if c.jitter > 0 {
+            d += time.Duration((rand.Float64()*2 - 1) * c.jitter * float64(d))
+            // Expire soon rather than never if the jitter exceeds d
+            if d <= 0 {
+                d = 1
+            }
+        }
+        return c.clock.Now().Add(d).UnixNano()
     }
-    c.items[k] = Item{
-        Object:     x,
-        Expiration: e,
+    return 0
+}
+
+// insert stores item under k and returns any items evicted to make room for
+// it. It must be called with c.mu held.
+func (c *cache) insert(k string, item Item) []keyAndValue {
+    var evicted []keyAndValue
+    old, found := c.items.Get(k)
+    if found && c.notifiesEvictions() {
+        reason := EvictReplaced
+        if c.expired(old) {
+            reason = EvictExpired
+        }
+        evicted = append(evicted, keyAndValue{k, old.Object, reason})
+    }
+    if c.evictor != nil || c.sizer != nil {
+        // Make room first so the new item can't be chosen to make room for
+        // itself
+        evicted = append(evicted, c.makeRoom(k, found, item.Object)...)
+    }
+    c.items.Set(k, item)
+    if !found {
+        c.count.Add(1)
+    }
+    c.expirations.update(k, item.Expiration)
+    if c.evictor != nil {
+        c.evictor.add(k)
     }
+    c.changed(k, item.Object)
+    return evicted
 }
 
 func (c *cache) SetDefault(k string, x interface{}) {
     c.Set(k, x, DefaultExpiration)
 }
 
+func (c *cache) DefaultExpiration() time.Duration {
+    return time.Duration(c.defaultExpiration.Load())
+}
+
+// SetDefaultExpiration changes the expiration given to items stored with
+// DefaultExpiration from now on. Items already in the cache keep theirs.
+func (c *cache) SetDefaultExpiration(d time.Duration) {
+    if d == DefaultExpiration {
+        d = NoExpiration
+    }
+    c.defaultExpiration.Store(int64(d))
+}
+
 func (c *cache) Add(k string, x interface{}, d time.Duration) error {
+    if err := c.checkKey(k); err != nil {
+        return err
+    }
     c.mu.Lock()
     _, found := c.get(k)
     if found {
         c.mu.Unlock()
         return fmt.Errorf("item %s already exists", k)
     }
-    c.set(k, x, d)
+    evicted := c.set(k, x, d)
     c.mu.Unlock()
+    c.notifyEvicted(evicted)
+    c.mirrorSet(k, x, d)
     return nil
 }
 
+// AddOrGet is like Add, but returns the live value already under k, with
+// added false, instead of an error. If k fails the key checks set
+// WithMaxKeyLength or WithKeyValidator, nothing is stored and the error is
+// passed to the WithErrorHandler handler.
+func (c *cache) AddOrGet(k string, x interface{}, d time.Duration) (actual interface{}, added bool) {
+    if err := c.checkKey(k); err != nil {
+        c.handleError(err)
+        return nil, false
+    }
+    c.mu.Lock()
+    if v, found := c.get(k); found {
+        c.mu.Unlock()
+        return v, false
+    }
+    evicted := c.set(k, x, d)
+    c.mu.Unlock()
+    c.notifyEvicted(evicted)
+    c.mirrorSet(k, x, d)
+    return x, true
+}
+
 func (c *cache) Replace(k string, x interface{}, d time.Duration) error {
+    if err := c.checkKey(k); err != nil {
+        return err
+    }
     c.mu.Lock()
     _, found := c.get(k)
     if !found {
         c.mu.Unlock()
         return fmt.Errorf("item %s doesn't exist", k)
     }
-    c.set(k, x, d)
+    evicted := c.set(k, x, d)
     c.mu.Unlock()
+    c.notifyEvicted(evicted)
+    c.mirrorSet(k, x, d)
     return nil
 }
 
+func (c *cache) SetIfAbsent(k string, x interface{}, d time.Duration) bool {
+    if err := c.checkKey(k); err != nil {
+        c.handleError(err)
+        return false
+    }
+    c.mu.Lock()
+    _, found := c.get(k)
+    if found {
+        c.mu.Unlock()
+        return false
+    }
+    evicted := c.set(k, x, d)
+    c.mu.Unlock()
+    c.notifyEvicted(evicted)
+    c.mirrorSet(k, x, d)
+    return true
+}
+
+// GetOrSet returns the live value under k with loaded true, or stores x for
+// d and returns it with loaded false if there is none, like sync.Map's
+// LoadOrStore. If k fails the key checks, nothing is stored, nil is returned
+// and the error is passed to the WithErrorHandler handler.
+func (c *cache) GetOrSet(k string, x interface{}, d time.Duration) (actual interface{}, loaded bool) {
+    if err := c.checkKey(k); err != nil {
+        c.handleError(err)
+        return nil, false
+    }
+    c.mu.Lock()
+    item, found := c.items.Get(k)
+    if found && !c.readExpired(item) && item.Object != cachedMiss {
+        item = c.access(k, item)
+        c.mu.Unlock()
+        c.stats.hit()
+        return item.Object, true
+    }
+    evicted := c.set(k, x, d)
+    c.mu.Unlock()
+    c.stats.miss()
+    c.notifyEvicted(evicted)
+    c.mirrorSet(k, x, d)
+    return x, false
+}
+
+// GetSet stores x under k for d and returns the live value it replaced, if
+// there was one, like Redis's GETSET. The old value is evicted as
+// EvictReplaced.
+func (c *cache) GetSet(k string, x interface{}, d time.Duration) (old interface{}, existed bool) {
+    if err := c.checkKey(k); err != nil {
+        c.handleError(err)
+        return nil, false
+    }
+    c.mu.Lock()
+    item, found := c.items.Get(k)
+    if found && !c.readExpired(item) && item.Object != cachedMiss {
+        old, existed = item.Object, true
+    }
+    evicted := c.set(k, x, d)
+    c.mu.Unlock()
+    c.notifyEvicted(evicted)
+    c.mirrorSet(k, x, d)
+    return old, existed
+}
+
+// CompareAndSwap stores new under k if the live item there is equal to old, as
+// determined by reflect.DeepEqual. d may be KeepExpiration.
+func (c *cache) CompareAndSwap(k string, old, new interface{}, d time.Duration) bool {
+    c.mu.Lock()
+    item, found := c.items.Get(k)
+    if !found || c.readExpired(item) || !reflect.DeepEqual(item.Object, old) {
+        c.mu.Unlock()
+        return false
+    }
+    var evicted []keyAndValue
+    if d == KeepExpiration {
+        item.Object = new
+        evicted = c.insert(k, item)
+    } else {
+        item = c.newItem(new, d)
+        evicted = c.insert(k, item)
+    }
+    c.mu.Unlock()
+    c.notifyEvicted(evicted)
+    c.mirrorItem(k, item)
+    return true
+}
+
+// Update replaces the item under k with the value returned by fn, which is
+// passed the current live value, if any. If fn returns keep as false the item
+// is deleted instead. An existing item keeps its expiration; a new one gets the
+// default expiration. fn runs with the write lock held and must not use the
+// cache. Update reports whether k holds a value afterwards.
+func (c *cache) Update(k string, fn func(old interface{}, found bool) (interface{}, bool)) bool {
+    // Only needed if k is created, but checked before taking the lock
+    keyErr := c.checkKey(k)
+    c.mu.Lock()
+    item, found := c.items.Get(k)
+    live := found && !c.readExpired(item) && item.Object != cachedMiss
+    var old interface{}
+    if live {
+        old = item.Object
+    }
+    x, keep := fn(old, live)
+    var evicted []keyAndValue
+    switch {
+    case !keep:
+        if v, deleted := c.delete(k); deleted {
+            c.stats.evicted()
+            evicted = []keyAndValue{{k, v, EvictDeleted}}
+        }
+    case live:
+        item.Object = x
+        evicted = c.insert(k, item)
+    case keyErr != nil:
+        c.mu.Unlock()
+        c.handleError(keyErr)
+        return false
+    default:
+        item = c.newItem(x, DefaultExpiration)
+        evicted = c.insert(k, item)
+    }
+    c.mu.Unlock()
+    c.notifyEvicted(evicted)
+    if keep {
+        c.mirrorItem(k, item)
+    } else {
+        c.mirrorDelete(k)
+    }
+    return keep
+}
+
+func (c *cache) Touch(k string, d time.Duration) bool {
+    return c.setExpiration(k, c.expiration(d))
+}
+
+// SetExpiration makes the live item under k expire after d, which is
+// interpreted as in Set, leaving its value alone. It returns false if there
+// is no such item.
+func (c *cache) SetExpiration(k string, d time.Duration) bool {
+    return c.setExpiration(k, c.expiration(d))
+}
+
+// Persist makes the live item under k never expire, including by going
+// unread if it was stored with SetWithIdle. It returns false if there is no
+// such item.
+func (c *cache) Persist(k string) bool {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    item, found := c.items.Get(k)
+    if !found || c.readExpired(item) || item.Object == cachedMiss {
+        return false
+    }
+    item.Expiration = 0
+    item.Idle = 0
+    item.BaseTTL = 0
+    c.items.Set(k, item)
+    c.expirations.remove(k)
+    return true
+}
+
+// ExpireAt makes the live item under k expire at deadline, immediately if it
+// has passed, and returns false if there is no such item.
+func (c *cache) ExpireAt(k string, deadline time.Time) bool {
+    return c.setExpiration(k, deadlineExpiration(deadline))
+}
+
+func (c *cache) setExpiration(k string, e int64) bool {
+    c.mu.Lock()
+    item, found := c.items.Get(k)
+    if !found || c.readExpired(item) || item.Object == cachedMiss {
+        c.mu.Unlock()
+        return false
+    }
+    item.Expiration = e
+    // An expiration set explicitly is kept rather than adapted
+    item.BaseTTL = 0
+    c.items.Set(k, item)
+    c.expirations.update(k, e)
+    c.mu.Unlock()
+    return true
+}
+
+func (c *cache) IncrementInt(k string, n int) (int, error) {
+    c.mu.Lock()
+    v, found := c.items.Get(k)
+    if !found || c.readExpired(v) || v.Object == cachedMiss {
+        c.mu.Unlock()
+        return 0, fmt.Errorf("item %s not found", k)
+    }
+    rv, ok := v.Object.(int)
+    if !ok {
+        c.mu.Unlock()
+        return 0, fmt.Errorf("the value for %s is not an int", k)
+    }
+    nv := rv + n
+    v.Object = nv
+    c.items.Set(k, v)
+    c.changed(k, nv)
+    c.mu.Unlock()
+    c.mirrorItem(k, v)
+    return nv, nil
+}
+
+func (c *cache) IncrementFloat64(k string, n float64) (float64, error) {
+    c.mu.Lock()
+    v, found := c.items.Get(k)
+    if !found || c.readExpired(v) || v.Object == cachedMiss {
+        c.mu.Unlock()
+        return 0, fmt.Errorf("item %s not found", k)
+    }
+    rv, ok := v.Object.(float64)
+    if !ok {
+        c.mu.Unlock()
+        return 0, fmt.Errorf("the value for %s is not a float64", k)
+    }
+    nv := rv + n
+    v.Object = nv
+    c.items.Set(k, v)
+    c.changed(k, nv)
+    c.mu.Unlock()
+    c.mirrorItem(k, v)
+    return nv, nil
+}
+
+func (c *cache) DecrementInt(k string, n int) (int, error) {
+    return c.IncrementInt(k, -n)
+}
+
+func (c *cache) DecrementFloat64(k string, n float64) (float64, error) {
+    return c.IncrementFloat64(k, -n)
+}
+
 func (c *cache) Get(k string) (interface{}, bool) {
+    if c.stats.recordsLatency() {
+        defer c.stats.latency.get.observe(time.Now())
+    }
+    if c.recordsReads() {
+        item, found := c.touchGet(k)
+        if !found || item.Object == cachedMiss {
+            c.stats.miss()
+            return nil, false
+        }
+        c.stats.hit()
+        c.refreshAhead(k, item)
+        return item.Object, true
+    }
     c.mu.RLock()
     // "Inlining" of get and Expired
-    item, found := c.items[k]
-    if !found {
+    item, found := c.items.Get(k)
+    if !found || item.Object == cachedMiss {
         c.mu.RUnlock()
+        c.stats.miss()
         return nil, false
     }
-    if item.Expiration > 0 {
-        if time.Now().UnixNano() > item.Expiration {
+    if item.Expiration > 0 && !c.janitorExpiresOnly {
+        if c.now() > item.Expiration {
             c.mu.RUnlock()
+            c.stats.miss()
             return nil, false
         }
     }
     c.mu.RUnlock()
+    if item.Idle > 0 {
+        // Extending the idle expiration needs the write lock
+        item, found = c.touchGet(k)
+        if !found {
+            c.stats.miss()
+            return nil, false
+        }
+    } else if c.evictor != nil {
+        c.bufferRead(k)
+    }
+    c.stats.hit()
+    c.refreshAhead(k, item)
+    return item.Object, true
+}
+
+// Peek returns the live value under k without counting as a read: it
+// doesn't move the item's idle expiration, LastAccess, standing with the
+// eviction policy or the hit and miss stats.
+func (c *cache) Peek(k string) (interface{}, bool) {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    item, found := c.items.Get(k)
+    if !found || c.readExpired(item) || item.Object == cachedMiss {
+        return nil, false
+    }
     return item.Object, true
 }
 
 func (c *cache) GetWithExpiration(k string) (interface{}, time.Time, bool) {
+    if c.recordsReads() {
+        item, found := c.touchGet(k)
+        if !found || item.Object == cachedMiss {
+            c.stats.miss()
+            return nil, time.Time{}, false
+        }
+        c.stats.hit()
+        if item.Expiration > 0 {
+            return item.Object, time.Unix(0, item.Expiration), true
+        }
+        return item.Object, time.Time{}, true
+    }
     c.mu.RLock()
     // "Inlining" of get and Expired
-    item, found := c.items[k]
-    if !found {
+    item, found := c.items.Get(k)
+    if !found || item.Object == cachedMiss {
         c.mu.RUnlock()
+        c.stats.miss()
         return nil, time.Time{}, false
     }
 
     if item.Expiration > 0 {
-        if time.Now().UnixNano() > item.Expiration {
+        if !c.janitorExpiresOnly && c.now() > item.Expiration {
             c.mu.RUnlock()
+            c.stats.miss()
             return nil, time.Time{}, false
         }
 
         c.mu.RUnlock()
+        if item.Idle > 0 {
+            item, found = c.touchGet(k)
+            if !found {
+                c.stats.miss()
+                return nil, time.Time{}, false
+            }
+        } else if c.evictor != nil {
+            c.bufferRead(k)
+        }
+        c.stats.hit()
         return item.Object, time.Unix(0, item.Expiration), true
     }
 
     c.mu.RUnlock()
+    if c.evictor != nil {
+        c.bufferRead(k)
+    }
+    c.stats.hit()
     return item.Object, time.Time{}, true
 }
 
+// touchGet looks k up under the write lock so the read can be recorded by
+// access.
+func (c *cache) touchGet(k string) (Item, bool) {
+    c.mu.Lock()
+    item, found := c.items.Get(k)
+    if !found || c.readExpired(item) {
+        c.mu.Unlock()
+        return Item{}, false
+    }
+    item = c.access(k, item)
+    c.mu.Unlock()
+    return item, true
+}
+
+// GetAndRefresh returns the live value under k and makes it expire after d
+// from now, which is interpreted as in Set, under one lock. Nothing changes if
+// there is no live value.
+func (c *cache) GetAndRefresh(k string, d time.Duration) (interface{}, bool) {
+    e := c.expiration(d)
+    c.mu.Lock()
+    item, found := c.items.Get(k)
+    if !found || c.readExpired(item) || item.Object == cachedMiss {
+        c.mu.Unlock()
+        c.stats.miss()
+        return nil, false
+    }
+    item = c.access(k, item)
+    item.Expiration = e
+    // An expiration set explicitly is kept rather than adapted
+    item.BaseTTL = 0
+    c.items.Set(k, item)
+    c.expirations.update(k, e)
+    c.mu.Unlock()
+    c.stats.hit()
+    return item.Object, true
+}
+
+// recordsReads reports whether every read has to go through access, and so
+// needs the write lock. Items with an idle expiration are always recorded.
+// Otherwise reads are only passed to the eviction policy, through
+// bufferRead.
+func (c *cache) recordsReads() bool {
+    return c.trackAccess
+}
+
+// access records a read of the live item stored under k: it becomes the most
+// recently used, its idle expiration is extended and its LastAccess updated.
+// It must be called with c.mu held for writing.
+func (c *cache) access(k string, item Item) Item {
+    if c.evictor != nil {
+        c.evictor.access(k)
+    }
+    if item.Idle <= 0 && !c.trackAccess {
+        return item
+    }
+    now := c.clock.Now()
+    if item.Idle > 0 {
+        item.Expiration = now.Add(item.Idle).UnixNano()
+        c.expirations.update(k, item.Expiration)
+    }
+    if c.trackAccess {
+        item.LastAccess = now.UnixNano()
+        item.AccessCount++
+    }
+    if c.adaptiveTTL != nil && item.BaseTTL > 0 && item.Idle <= 0 {
+        if d := c.adaptiveTTL(item.AccessCount, item.BaseTTL); d > 0 {
+            item.Expiration = item.Created + int64(d)
+            c.expirations.update(k, item.Expiration)
+        }
+    }
+    c.items.Set(k, item)
+    return item
+}
+
+// GetWithMetadata returns the live value under k with the times it was
+// stored and last read. It doesn't count as a read itself.
+func (c *cache) GetWithMetadata(k string) (value interface{}, created, lastAccess time.Time, found bool) {
+    c.mu.RLock()
+    item, found := c.items.Get(k)
+    c.mu.RUnlock()
+    if !found || c.readExpired(item) || item.Object == cachedMiss {
+        return nil, time.Time{}, time.Time{}, false
+    }
+    return item.Object, unixTime(item.Created), unixTime(item.LastAccess), true
+}
+
+// unixTime converts a Unix nanosecond time, leaving 0 as the zero Time.
+func unixTime(n int64) time.Time {
+    if n == 0 {
+        return time.Time{}
+    }
+    return time.Unix(0, n)
+}
+
+func (c *cache) TTL(k string) (time.Duration, bool) {
+    c.mu.RLock()
+    item, found := c.items.Get(k)
+    c.mu.RUnlock()
+    if !found || item.Object == cachedMiss {
+        return 0, false
+    }
+    if item.Expiration == 0 {
+        return NoExpiration, true
+    }
+    ttl := item.Expiration - c.now()
+    if ttl < 0 {
+        // Found until the janitor deletes it if it leaves expiring to that
+        return 0, c.janitorExpiresOnly
+    }
+    return time.Duration(ttl), true
+}
+
+// GetStale returns the value under k even if it has expired, as long as it
+// hasn't been deleted yet, reporting whether it has expired.
+func (c *cache) GetStale(k string) (value interface{}, expired bool, found bool) {
+    c.mu.RLock()
+    item, found := c.items.Get(k)
+    c.mu.RUnlock()
+    if !found || item.Object == cachedMiss {
+        return nil, false, false
+    }
+    return item.Object, c.expired(item), true
+}
+
+// get returns the value under k, treating a cached miss as absent. It must be
+// called with c.mu held.
 func (c *cache) get(k string) (interface{}, bool) {
-    item, found := c.items[k]
-    if !found {
+    item, found := c.items.Get(k)
+    if !found || item.Object == cachedMiss {
         return nil, false
     }
-    if item.Expiration > 0 {
-        if time.Now().UnixNano() > item.Expiration {
+    if item.Expiration > 0 && !c.janitorExpiresOnly {
+        if c.now() > item.Expiration {
             return nil, false
         }
     }
@@ -151,85 +853,362 @@ func (c *cache) get(k string) (interface{}, bool) {
 }
 
 func (c *cache) Delete(k string) {
+    if err := c.DeleteThrough(k); err != nil {
+        c.handleError(err)
+    }
+}
+
+// DeleteThrough is like Delete, but returns the error from the write-through
+// hook set WithWriteThrough, which is called once the item is deleted from
+// the cache, whether or not the cache held it.
+func (c *cache) DeleteThrough(k string) error {
     c.mu.Lock()
     v, evicted := c.delete(k)
     c.mu.Unlock()
     if evicted {
-        c.onEvicted(k, v)
+        c.stats.evicted()
+        c.notifyEvicted([]keyAndValue{{k, v, EvictDeleted}})
+    }
+    return c.deleteThrough(k)
+}
+
+func (c *cache) GetAndDelete(k string) (interface{}, bool) {
+    c.mu.Lock()
+    item, found := c.items.Get(k)
+    if !found {
+        c.mu.Unlock()
+        return nil, false
+    }
+    c.delete(k)
+    c.mu.Unlock()
+    c.stats.evicted()
+    c.notifyEvicted([]keyAndValue{{k, item.Object, EvictDeleted}})
+    c.mirrorDelete(k)
+    if item.Object == cachedMiss || c.readExpired(item) {
+        return nil, false
     }
+    return item.Object, true
+}
+
+// RenameKey moves the live item under oldKey, with its expiration, to newKey,
+// replacing any item already there. The move itself isn't an eviction and
+// doesn't call onEvicted for oldKey.
+func (c *cache) RenameKey(oldKey, newKey string) bool {
+    if err := c.checkKey(newKey); err != nil {
+        c.handleError(err)
+        return false
+    }
+    c.mu.Lock()
+    item, found := c.items.Get(oldKey)
+    if !found || c.readExpired(item) || item.Object == cachedMiss {
+        c.mu.Unlock()
+        return false
+    }
+    if oldKey == newKey {
+        c.mu.Unlock()
+        return true
+    }
+    c.delete(oldKey)
+    evicted := c.insert(newKey, item)
+    c.mu.Unlock()
+    c.notifyEvicted(evicted)
+    c.mirrorItem(newKey, item)
+    c.mirrorDelete(oldKey)
+    return true
+}
+
+// CopyKey stores the live value under src under dst as well, expiring after
+// d, or when src's item does if d is KeepExpiration. The value itself isn't
+// copied, so both keys share whatever it refers to. An item already under dst
+// is replaced, and evicted as EvictReplaced. It returns false if there is no
+// live item under src.
+func (c *cache) CopyKey(src, dst string, d time.Duration) bool {
+    if err := c.checkKey(dst); err != nil {
+        c.handleError(err)
+        return false
+    }
+    copied := c.newItem(nil, d)
+    c.mu.Lock()
+    item, found := c.items.Get(src)
+    if !found || c.readExpired(item) || item.Object == cachedMiss {
+        c.mu.Unlock()
+        return false
+    }
+    copied.Object = item.Object
+    if d == KeepExpiration {
+        copied.Expiration = item.Expiration
+        copied.Idle = item.Idle
+    }
+    evicted := c.insert(dst, copied)
+    c.mu.Unlock()
+    c.notifyEvicted(evicted)
+    c.mirrorItem(dst, copied)
+    return true
 }
 
 func (c *cache) delete(k string) (interface{}, bool) {
-    if c.onEvicted != nil {
-        if v, found := c.items[k]; found {
-            delete(c.items, k)
-            return v.Object, true
-        }
+    v, found := c.items.Get(k)
+    if !found {
+        return nil, false
+    }
+    c.items.Delete(k)
+    c.count.Add(-1)
+    c.expirations.remove(k)
+    c.unwatch(k)
+    if c.evictor != nil {
+        c.evictor.remove(k)
+    }
+    if c.sizer != nil {
+        c.bytes -= c.sizes[k]
+        delete(c.sizes, k)
     }
-    delete(c.items, k)
-    return nil, false
+    return v.Object, true
 }
 
 type keyAndValue struct {
-    key   string
-    value interface{}
+    key    string
+    value  interface{}
+    reason EvictReason
 }
 
 func (c *cache) DeleteExpired() {
+    c.PurgeExpired()
+}
+
+// PurgeExpired deletes all expired items now, as the janitor does, calling
+// the eviction callbacks for them with the reason EvictExpired, and returns
+// how many it deleted.
+func (c *cache) PurgeExpired() int {
+    return c.purgeExpired(0)
+}
+
+// cleanup is the janitor's run: PurgeExpired, or only as much of it as fits
+// in the WithIncrementalCleanup budget.
+func (c *cache) cleanup() {
+    c.purgeExpired(c.cleanupBudget)
+}
+
+// purgeExpiredCheck is how many expired items purgeExpired deletes between
+// looks at the time it has held the lock for.
+const purgeExpiredCheck = 32
+
+// purgeExpired deletes expired items, soonest expired first, and stops once
+// it has held the lock for budget if budget is more than zero.
+func (c *cache) purgeExpired(budget time.Duration) int {
     var evictedItems []keyAndValue
-    now := time.Now().UnixNano()
+    now := c.now()
     c.mu.Lock()
-    for k, v := range c.items {
-        // "Inlining" of expired
-        if v.Expiration > 0 && now > v.Expiration {
-            ov, evicted := c.delete(k)
-            if evicted {
-                evictedItems = append(evictedItems, keyAndValue{k, ov})
-            }
+    // The budget bounds how long others wait for the lock, so it's measured
+    // in real time rather than by c.clock
+    start := time.Now()
+    notify := c.notifiesEvictions()
+    expired := 0
+    for n := 1; ; n++ {
+        if budget > 0 && n%purgeExpiredCheck == 0 && time.Since(start) >= budget {
+            break
+        }
+        k, e, ok := c.expirations.next()
+        if !ok || now <= e {
+            break
+        }
+        ov, found := c.delete(k)
+        if !found {
+            c.expirations.remove(k)
+            continue
+        }
+        expired++
+        if notify {
+            evictedItems = append(evictedItems, keyAndValue{k, ov, EvictExpired})
         }
     }
     c.mu.Unlock()
-    for _, v := range evictedItems {
-        c.onEvicted(v.key, v.value)
+    c.stats.expired(expired)
+    c.notifyEvicted(evictedItems)
+    return expired
+}
+
+// pendingExpiredBudget is about how many expired items deleteExpiredIfPending
+// counts per call on average.
+const pendingExpiredBudget = 64
+
+// deleteExpiredIfPending runs DeleteExpired if the cache holds at least the
+// WithMaxPendingExpired number of expired items. It only looks at the soonest
+// expiration unless that has passed, and then counts on every
+// maxPendingExpired/pendingExpiredBudget-th call, so each call costs
+// pendingExpiredBudget steps at most on average.
+func (c *cache) deleteExpiredIfPending() {
+    now := c.now()
+    c.mu.RLock()
+    if _, e, ok := c.expirations.next(); !ok || now <= e {
+        c.mu.RUnlock()
+        return
+    }
+    if step := uint32(c.maxPendingExpired / pendingExpiredBudget); step > 1 && c.pendingChecks.Add(1)%step != 0 {
+        c.mu.RUnlock()
+        return
+    }
+    n := c.expirations.countExpired(now, c.maxPendingExpired)
+    c.mu.RUnlock()
+    if n >= c.maxPendingExpired {
+        c.DeleteExpired()
     }
 }
 
+// OnEvicted sets the callback for items leaving the cache, replacing any
+// added with AddOnEvicted. A nil f removes them all.
 func (c *cache) OnEvicted(f func(string, interface{})) {
     c.mu.Lock()
-    c.onEvicted = f
+    cb := c.evictCallbacks()
+    if f == nil {
+        cb.onEvicted = nil
+    } else {
+        cb.onEvicted = []func(string, interface{}){f}
+    }
+    c.callbacks.Store(&cb)
     c.mu.Unlock()
 }
 
-func (c *cache) Save(w io.Writer) (err error) {
+// AddOnEvicted adds a callback for items leaving the cache, to be called
+// after those already set, in the order they were added.
+func (c *cache) AddOnEvicted(f func(string, interface{})) {
+    c.mu.Lock()
+    cb := c.evictCallbacks()
+    // Copy rather than append in place so a notification already under way
+    // keeps the slice it started with
+    fs := make([]func(string, interface{}), len(cb.onEvicted), len(cb.onEvicted)+1)
+    copy(fs, cb.onEvicted)
+    cb.onEvicted = append(fs, f)
+    c.callbacks.Store(&cb)
+    c.mu.Unlock()
+}
+
+// Save writes the cache's items to w, followed by the time of the snapshot
+// for LoadWithRelativeTTL. Load only reads the items, so it can load dumps
+// from before the snapshot time was added and vice versa.
+func (c *cache) Save(w io.Writer) error {
     enc := gob.NewEncoder(w)
-    defer func() {
-        if x := recover(); x != nil {
-            err = fmt.Errorf("error registering item types with Gob library")
-        }
-    }()
     c.mu.RLock()
     defer c.mu.RUnlock()
-    for _, v := range c.items {
-        gob.Register(v.Object)
+    items := c.snapshot()
+    for k, v := range items {
+        if err := checkGobType(k, v.Object); err != nil {
+            return err
+        }
+    }
+    if err := enc.Encode(&items); err != nil {
+        return err
+    }
+    return enc.Encode(c.now())
+}
+
+// gobTypes records the concrete types already passed to gob.Register, which
+// is process-global, so every Save doesn't register each stored value again.
+var gobTypes sync.Map
+
+func init() {
+    // Callers can't declare the unexported type of cached misses with
+    // RegisterTypes, so it always is
+    gob.Register(cachedMiss)
+    gobTypes.Store(reflect.TypeOf(cachedMiss), struct{}{})
+}
+
+// gobTypesDeclared is set by the first call to RegisterTypes, after which
+// Save only encodes the types declared and those gob knows without
+// registration.
+var gobTypesDeclared atomic.Bool
+
+// RegisterTypes registers the concrete type of each sample with gob.Register
+// for Save and SaveStream, and panics as gob.Register does if a type's name
+// is already registered to another type. Once it has been called, saves no
+// longer register types as they find them: saving an item of any other type
+// than those declared, the predeclared types such as int and string, and
+// slices of them fails with an error naming the item's key.
+func RegisterTypes(samples ...interface{}) {
+    for _, v := range samples {
+        if v == nil {
+            continue
+        }
+        gob.Register(v)
+        gobTypes.Store(reflect.TypeOf(v), struct{}{})
     }
-    err = enc.Encode(&c.items)
-    return
+    gobTypesDeclared.Store(true)
+}
+
+// checkGobType makes sure v, stored under k, can be encoded as an interface
+// value: after RegisterTypes it reports a type that wasn't declared, and
+// before it registers the type itself.
+func checkGobType(k string, v interface{}) error {
+    if v == nil {
+        return nil
+    }
+    if !gobTypesDeclared.Load() {
+        registerGobType(v)
+        return nil
+    }
+    t := reflect.TypeOf(v)
+    if _, ok := gobTypes.Load(t); ok || isGobBasic(t) {
+        return nil
+    }
+    return fmt.Errorf("cache: type %s of item %s is not registered with RegisterTypes", t, k)
+}
+
+// isGobBasic reports whether gob registers t itself: the predeclared types
+// and slices of them.
+func isGobBasic(t reflect.Type) bool {
+    if t.Kind() == reflect.Slice {
+        t = t.Elem()
+    }
+    return t.PkgPath() == "" && t.Name() != "" && t.Kind() != reflect.Interface
+}
+
+func registerGobType(v interface{}) {
+    if _, loaded := gobTypes.LoadOrStore(reflect.TypeOf(v), struct{}{}); loaded {
+        return
+    }
+    defer func() {
+        // The type's name is already registered to a different type. That
+        // doesn't stop the other items being saved; if the value can't be
+        // encoded Encode reports it.
+        recover()
+    }()
+    gob.Register(v)
 }
 
 func (c *cache) SaveFile(name string) error {
-    file, err := os.Create(name)
+    return writeFileAtomic(name, c.Save)
+}
+
+// writeFileAtomic writes to a temporary file next to name and renames it over
+// name once write and the file's sync have succeeded, so a failed or
+// interrupted save leaves any existing file untouched.
+func writeFileAtomic(name string, write func(io.Writer) error) error {
+    mode := os.FileMode(0644)
+    if fi, err := os.Stat(name); err == nil {
+        mode = fi.Mode().Perm()
+    }
+    file, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp*")
     if err != nil {
         return err
     }
-    err = c.Save(file)
+    tmp := file.Name()
+    err = write(file)
+    if err == nil {
+        err = file.Chmod(mode)
+    }
+    if err == nil {
+        err = file.Sync()
+    }
+    if errFile := file.Close(); err == nil {
+        err = errFile
+    }
+    if err == nil {
+        err = os.Rename(tmp, name)
+    }
     if err != nil {
-        errFile := file.Close()
-        if errFile != nil {
-            return errFile
-        }
+        os.Remove(tmp)
         return err
     }
-    return file.Close()
+    return nil
 }
 
 func (c *cache) Load(r io.Reader) error {
@@ -237,16 +1216,51 @@ func (c *cache) Load(r io.Reader) error {
     items := map[string]Item{}
     err := dec.Decode(&items)
     if err == nil {
-        c.mu.Lock()
-        defer c.mu.Unlock()
+        c.loadItems(items)
+    }
+    return err
+}
+
+// LoadWithRelativeTTL is like Load, except that each item is given the time
+// it had left when the cache was saved rather than keeping its absolute
+// expiration, so time spent between Save and Load doesn't count against it.
+// Dumps without a snapshot time are loaded as Load would.
+func (c *cache) LoadWithRelativeTTL(r io.Reader) error {
+    dec := gob.NewDecoder(r)
+    items := map[string]Item{}
+    if err := dec.Decode(&items); err != nil {
+        return err
+    }
+    var savedAt int64
+    err := dec.Decode(&savedAt)
+    if err != nil && err != io.EOF {
+        return err
+    }
+    if err == nil {
+        shift := c.now() - savedAt
         for k, v := range items {
-            ov, found := c.items[k]
-            if !found || ov.Expired() {
-                c.items[k] = v
+            if v.Expiration > 0 {
+                v.Expiration += shift
+                items[k] = v
             }
         }
     }
-    return err
+    c.loadItems(items)
+    return nil
+}
+
+// loadItems adds items to the cache without overwriting live items.
+func (c *cache) loadItems(items map[string]Item) {
+    var evicted []keyAndValue
+    c.mu.Lock()
+    for k, v := range items {
+        ov, found := c.items.Get(k)
+        if !found || c.expired(ov) {
+            evicted = append(evicted, c.insert(k, v)...)
+        }
+    }
+    c.mu.Unlock()
+    c.notifyEvicted(evicted)
 }
 
 func (c *cache) LoadFile(name string) error {
@@ -265,31 +1279,217 @@ func (c *cache) LoadFile(name string) error {
     return fp.Close()
 }
 
+func (c *cache) LoadFileWithRelativeTTL(name string) error {
+    fp, err := os.Open(name)
+    if err != nil {
+        return err
+    }
+    err = c.LoadWithRelativeTTL(fp)
+    if err != nil {
+        errFile := fp.Close()
+        if errFile != nil {
+            return errFile
+        }
+        return err
+    }
+    return fp.Close()
+}
+
 func (c *cache) Items() map[string]Item {
     c.mu.RLock()
     defer c.mu.RUnlock()
-    m := make(map[string]Item, len(c.items))
-    now := time.Now().UnixNano()
-    for k, v := range c.items {
-        if v.Expiration > 0 {
-            if now > v.Expiration {
-                continue
-            }
+    m := make(map[string]Item, c.items.Len())
+    now := c.now()
+    c.items.Range(func(k string, v Item) bool {
+        if !live(v, now) {
+            return true
         }
         m[k] = v
+        return true
+    })
+    return m
+}
+
+// KeyedItem is an item returned by ItemsSorted, with its key.
+type KeyedItem struct {
+    Key  string
+    Item Item
+}
+
+// ItemsSorted returns the unexpired items like Items, ordered by key.
+func (c *cache) ItemsSorted() []KeyedItem {
+    items := c.Items()
+    sorted := make([]KeyedItem, 0, len(items))
+    for k, v := range items {
+        sorted = append(sorted, KeyedItem{k, v})
     }
+    sort.Slice(sorted, func(i, j int) bool {
+        return sorted[i].Key < sorted[j].Key
+    })
+    return sorted
+}
+
+// ItemsFiltered returns the unexpired items for which pred returns true. pred
+// runs with the cache's read lock held, so it must not modify the cache.
+func (c *cache) ItemsFiltered(pred func(k string, item Item) bool) map[string]Item {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    m := make(map[string]Item)
+    now := c.now()
+    c.items.Range(func(k string, v Item) bool {
+        if !live(v, now) {
+            return true
+        }
+        if pred(k, v) {
+            m[k] = v
+        }
+        return true
+    })
     return m
 }
 
+// ForEach calls fn for each unexpired item until fn returns false. fn runs
+// with the cache's read lock held, so it must not modify the cache; doing so
+// deadlocks.
+func (c *cache) ForEach(fn func(k string, v interface{}) bool) {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    now := c.now()
+    c.items.Range(func(k string, v Item) bool {
+        if !live(v, now) {
+            return true
+        }
+        return fn(k, v.Object)
+    })
+}
+
+// Keys returns the keys of all unexpired items, in no particular order.
+func (c *cache) Keys() []string {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    keys := make([]string, 0, c.items.Len())
+    now := c.now()
+    c.items.Range(func(k string, v Item) bool {
+        if !live(v, now) {
+            return true
+        }
+        keys = append(keys, k)
+        return true
+    })
+    return keys
+}
+
+// RandomKeys returns up to n unexpired keys chosen at random, without
+// visiting the rest of the cache. The randomness is only as good as the
+// store's iteration order: the default store takes the first keys of a Go map
+// range, which starts at a random place but returns the keys after it in a
+// fixed order, so keys often come back together. Use it for sampling, not
+// where a fair choice matters.
+func (c *cache) RandomKeys(n int) []string {
+    if n <= 0 {
+        return nil
+    }
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    keys := make([]string, 0, min(n, c.items.Len()))
+    now := c.now()
+    c.items.Range(func(k string, v Item) bool {
+        if !live(v, now) {
+            return true
+        }
+        keys = append(keys, k)
+        return len(keys) < n
+    })
+    return keys
+}
+
 func (c *cache) ItemCount() int {
     c.mu.RLock()
-    n := len(c.items)
+    n := c.items.Len()
     c.mu.RUnlock()
     return n
 }
 
+// ApproxItemCount returns the number of items like ItemCount, but without
+// taking the lock. It's approximate in the same way: it includes expired
+// items not yet deleted, and may miss changes still under way.
+func (c *cache) ApproxItemCount() int64 {
+    return c.count.Load()
+}
+
+// LiveCount returns the number of unexpired items, which unlike ItemCount
+// doesn't include expired items the janitor hasn't deleted yet.
+func (c *cache) LiveCount() int {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    n := 0
+    now := c.now()
+    c.items.Range(func(_ string, v Item) bool {
+        if live(v, now) {
+            n++
+        }
+        return true
+    })
+    return n
+}
+
 func (c *cache) Flush() {
     c.mu.Lock()
-    c.items = map[string]Item{}
+    c.flush()
+    c.mu.Unlock()
+}
+
+// FlushWithCallbacks deletes all items like Flush, but calls the eviction
+// callbacks for each of them, with the reason EvictDeleted.
+func (c *cache) FlushWithCallbacks() {
+    c.mu.Lock()
+    evicted := c.flushEvicted()
     c.mu.Unlock()
+    c.notifyEvicted(evicted)
+}
+
+// Reset deletes all items like FlushWithCallbacks and sets the default
+// expiration to d, as SetDefaultExpiration does, under the same lock.
+func (c *cache) Reset(d time.Duration) {
+    c.mu.Lock()
+    evicted := c.flushEvicted()
+    c.SetDefaultExpiration(d)
+    c.mu.Unlock()
+    c.notifyEvicted(evicted)
+}
+
+// flushEvicted flushes the cache and returns the items it held to notify of.
+// It must be called with c.mu held.
+func (c *cache) flushEvicted() []keyAndValue {
+    evicted := make([]keyAndValue, 0, c.items.Len())
+    c.items.Range(func(k string, v Item) bool {
+        evicted = append(evicted, keyAndValue{k, v.Object, EvictDeleted})
+        return true
+    })
+    c.flush()
+    for range evicted {
+        c.stats.evicted()
+    }
+    return evicted
+}
+
+// flush must be called with c.mu held.
+func (c *cache) flush() {
+    if _, ok := c.items.(mapStore); ok {
+        c.items = mapStore{}
+    } else {
+        for _, k := range c.storeKeys() {
+            c.items.Delete(k)
+        }
+    }
+    c.count.Store(0)
+    c.expirations.reset()
+    c.unwatchAll()
+    if c.evictor != nil {
+        c.evictor.reset()
+    }
+    if c.sizer != nil {
+        c.bytes = 0
+        c.sizes = make(map[string]int64)
+    }
 }
@@ -0,0 +1,952 @@
+package cache
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "runtime"
+    "strconv"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+type saveTestA struct {
+    A int
+}
+
+type saveTestB struct {
+    B string
+}
+
+func TestSaveConcurrentTypes(t *testing.T) {
+    a := New(NoExpiration, 0)
+    b := New(NoExpiration, 0)
+    for i := 0; i < 100; i++ {
+        a.Set(strconv.Itoa(i), saveTestA{i}, DefaultExpiration)
+        b.Set(strconv.Itoa(i), saveTestB{strconv.Itoa(i)}, DefaultExpiration)
+    }
+
+    var bufs [2]bytes.Buffer
+    var errs [2]error
+    var wg sync.WaitGroup
+    for i, c := range []*Cache{a, b} {
+        wg.Add(1)
+        go func(i int, c *Cache) {
+            defer wg.Done()
+            errs[i] = c.Save(&bufs[i])
+        }(i, c)
+    }
+    wg.Wait()
+    for i, err := range errs {
+        if err != nil {
+            t.Fatalf("Save of cache %d: %v", i, err)
+        }
+    }
+
+    la := New(NoExpiration, 0)
+    if err := la.Load(&bufs[0]); err != nil {
+        t.Fatalf("Load of a: %v", err)
+    }
+    lb := New(NoExpiration, 0)
+    if err := lb.Load(&bufs[1]); err != nil {
+        t.Fatalf("Load of b: %v", err)
+    }
+    for i := 0; i < 100; i++ {
+        k := strconv.Itoa(i)
+        if x, _ := la.Get(k); x != (saveTestA{i}) {
+            t.Errorf("a[%s] = %#v, want %#v", k, x, saveTestA{i})
+        }
+        if x, _ := lb.Get(k); x != (saveTestB{k}) {
+            t.Errorf("b[%s] = %#v, want %#v", k, x, saveTestB{k})
+        }
+    }
+}
+
+func TestOnEvictedOverwrite(t *testing.T) {
+    overwrite := map[string]func(c *Cache, x interface{}){
+        "Set": func(c *Cache, x interface{}) {
+            c.Set("k", x, DefaultExpiration)
+        },
+        "Replace": func(c *Cache, x interface{}) {
+            if err := c.Replace("k", x, DefaultExpiration); err != nil {
+                t.Fatalf("Replace: %v", err)
+            }
+        },
+        "SetMany": func(c *Cache, x interface{}) {
+            c.SetMany(map[string]interface{}{"k": x}, DefaultExpiration)
+        },
+    }
+    for name, set := range overwrite {
+        t.Run(name, func(t *testing.T) {
+            c := New(NoExpiration, 0)
+            var evicted []interface{}
+            c.OnEvicted(func(k string, v interface{}) {
+                evicted = append(evicted, v)
+            })
+            c.Set("k", "old", DefaultExpiration)
+            set(c, "new")
+            if len(evicted) != 1 || evicted[0] != "old" {
+                t.Fatalf("evicted %v, want [old]", evicted)
+            }
+            if x, _ := c.Get("k"); x != "new" {
+                t.Errorf("Get = %v, want new", x)
+            }
+        })
+    }
+}
+
+type evictEvent struct {
+    key    string
+    value  interface{}
+    reason EvictReason
+}
+
+func TestRenameKey(t *testing.T) {
+    c := New(NoExpiration, 0)
+    var events []evictEvent
+    c.OnEvictedReason(func(k string, v interface{}, reason EvictReason) {
+        events = append(events, evictEvent{k, v, reason})
+    })
+    c.Set("a", "A", time.Hour)
+    c.Set("b", "B", DefaultExpiration)
+    _, wantExp, _ := c.GetWithExpiration("a")
+
+    if !c.RenameKey("a", "b") {
+        t.Fatal("RenameKey(a, b) = false, want true")
+    }
+    if _, found := c.Get("a"); found {
+        t.Error("a still found after being renamed")
+    }
+    x, exp, found := c.GetWithExpiration("b")
+    if !found || x != "A" || !exp.Equal(wantExp) {
+        t.Errorf("b = %v, %v, %v; want A, %v, true", x, exp, found, wantExp)
+    }
+    want := []evictEvent{{"b", "B", EvictReplaced}}
+    if len(events) != 1 || events[0] != want[0] {
+        t.Errorf("evicted %v, want %v", events, want)
+    }
+
+    events = nil
+    if c.RenameKey("missing", "b") {
+        t.Error("RenameKey(missing, b) = true, want false")
+    }
+    c.Set("expired", "E", time.Nanosecond)
+    time.Sleep(time.Millisecond)
+    if c.RenameKey("expired", "b") {
+        t.Error("RenameKey(expired, b) = true, want false")
+    }
+    if x, _ := c.Get("b"); x != "A" {
+        t.Errorf("b = %v after failed renames, want A", x)
+    }
+    if len(events) != 0 {
+        t.Errorf("failed renames evicted %v", events)
+    }
+}
+
+// waitFor polls cond until it holds, failing the test if it doesn't within
+// a second.
+func waitFor(t *testing.T, what string, cond func() bool) {
+    t.Helper()
+    deadline := time.Now().Add(time.Second)
+    for !cond() {
+        if time.Now().After(deadline) {
+            t.Fatalf("timed out waiting for %s", what)
+        }
+        time.Sleep(time.Millisecond)
+    }
+}
+
+func TestOnEvictedPanic(t *testing.T) {
+    var mu sync.Mutex
+    var errs []error
+    var evicted []string
+    c := New(NoExpiration, 5*time.Millisecond, WithErrorHandler(func(err error) {
+        mu.Lock()
+        errs = append(errs, err)
+        mu.Unlock()
+    }))
+    defer c.Close()
+    c.OnEvicted(func(k string, v interface{}) {
+        if v == "boom" {
+            panic("boom")
+        }
+        mu.Lock()
+        evicted = append(evicted, k)
+        mu.Unlock()
+    })
+    errCount := func() int {
+        mu.Lock()
+        defer mu.Unlock()
+        return len(errs)
+    }
+    wasEvicted := func(k string) bool {
+        mu.Lock()
+        defer mu.Unlock()
+        for _, e := range evicted {
+            if e == k {
+                return true
+            }
+        }
+        return false
+    }
+
+    c.Set("deleted", "boom", DefaultExpiration)
+    c.Delete("deleted")
+    if errCount() != 1 {
+        t.Fatalf("error handler called %d times after Delete, want 1", errCount())
+    }
+    mu.Lock()
+    err := errs[0]
+    mu.Unlock()
+    if msg := err.Error(); !strings.Contains(msg, "deleted") || !strings.Contains(msg, "boom") {
+        t.Errorf("error %q doesn't name the key and the panic", msg)
+    }
+    if _, found := c.Get("deleted"); found {
+        t.Error("item still found after a Delete whose callback panicked")
+    }
+
+    // The janitor survives a panic in a callback it calls
+    c.Set("expired", "boom", time.Millisecond)
+    waitFor(t, "the janitor to report the panic", func() bool { return errCount() == 2 })
+    c.Set("expired2", "ok", time.Millisecond)
+    waitFor(t, "the janitor to evict expired2", func() bool { return wasEvicted("expired2") })
+
+    c.Set("later", "ok", DefaultExpiration)
+    c.Delete("later")
+    if !wasEvicted("later") {
+        t.Error("later Delete didn't call the callback")
+    }
+}
+
+func TestIncrementalCleanupLockHold(t *testing.T) {
+    const budget = 5 * time.Millisecond
+    c := New(NoExpiration, 0, WithIncrementalCleanup(budget))
+    for i := 0; i < 50000; i++ {
+        c.Set(strconv.Itoa(i), i, time.Nanosecond)
+    }
+    time.Sleep(time.Millisecond)
+
+    done := make(chan struct{})
+    var maxWait time.Duration
+    go func() {
+        defer close(done)
+        for c.ApproxItemCount() > 0 {
+            start := time.Now()
+            c.mu.RLock()
+            c.mu.RUnlock()
+            if wait := time.Since(start); wait > maxWait {
+                maxWait = wait
+            }
+        }
+    }()
+    runs := 0
+    for c.ApproxItemCount() > 0 {
+        c.cleanup()
+        runs++
+    }
+    <-done
+    if runs < 2 {
+        t.Fatalf("cleanup deleted everything in %d run; the test needs more expired items", runs)
+    }
+    // Allow for scheduling delays on a loaded machine
+    if maxWait > 4*budget {
+        t.Errorf("a reader waited %v for the lock, want no more than about %v", maxWait, budget)
+    }
+    t.Logf("%d runs, longest wait %v", runs, maxWait)
+}
+
+func BenchmarkGetParallel(b *testing.B) {
+    const n = 1000
+    keys := make([]string, n)
+    for i := range keys {
+        keys[i] = strconv.Itoa(i)
+    }
+    configs := []struct {
+        name string
+        opts []Option
+    }{
+        {"Unlimited", nil},
+        {"LRU", []Option{WithMaxItems(2 * n), WithEvictionPolicy(LRU)}},
+        {"LFU", []Option{WithMaxItems(2 * n), WithEvictionPolicy(LFU)}},
+    }
+    for _, cfg := range configs {
+        b.Run(cfg.name, func(b *testing.B) {
+            c := New(NoExpiration, 0, cfg.opts...)
+            for i, k := range keys {
+                c.Set(k, i, DefaultExpiration)
+            }
+            b.ResetTimer()
+            b.RunParallel(func(pb *testing.PB) {
+                i := 0
+                for pb.Next() {
+                    c.Get(keys[i%n])
+                    i++
+                }
+            })
+        })
+    }
+}
+
+func TestCachedMissIsAbsent(t *testing.T) {
+    c := New(NoExpiration, 0)
+    var evicted []string
+    c.OnEvicted(func(k string, v interface{}) {
+        evicted = append(evicted, k)
+    })
+    c.SetMiss("m", DefaultExpiration)
+
+    if x, found := c.GetAndDelete("m"); found {
+        t.Errorf("GetAndDelete = %v, true; want a miss", x)
+    }
+    c.SetMiss("m", DefaultExpiration)
+    c.Update("m", func(old interface{}, found bool) (interface{}, bool) {
+        if found || old != nil {
+            t.Errorf("Update passed %v, %v; want nil, false", old, found)
+        }
+        return nil, false
+    })
+    c.SetMiss("m", DefaultExpiration)
+    if len(c.Items()) != 0 || len(c.Keys()) != 0 || len(c.RandomKeys(1)) != 0 {
+        t.Errorf("Items, Keys or RandomKeys include a cached miss")
+    }
+    if n := c.LiveCount(); n != 0 {
+        t.Errorf("LiveCount = %d, want 0", n)
+    }
+    if n := c.CountByPrefix("m"); n != 0 {
+        t.Errorf("CountByPrefix = %d, want 0", n)
+    }
+    c.ForEach(func(k string, v interface{}) bool {
+        t.Errorf("ForEach called for cached miss %s", k)
+        return true
+    })
+    if _, found := c.TTL("m"); found {
+        t.Error("TTL found a cached miss")
+    }
+
+    var buf bytes.Buffer
+    if err := c.SaveJSON(&buf); err != nil {
+        t.Fatalf("SaveJSON: %v", err)
+    }
+    loaded := New(NoExpiration, 0)
+    if err := loaded.LoadJSON(&buf); err != nil {
+        t.Fatalf("LoadJSON: %v", err)
+    }
+    if x, found := loaded.Get("m"); found {
+        t.Errorf("Get after LoadJSON = %v, true; want a miss", x)
+    }
+    if _, isMiss, _ := loaded.GetEntry("m"); !isMiss {
+        t.Error("LoadJSON didn't restore the cached miss")
+    }
+
+    if err := c.Add("m", 1, DefaultExpiration); err != nil {
+        t.Errorf("Add over a cached miss: %v", err)
+    }
+    c.SetMiss("m", DefaultExpiration)
+    if !c.SetIfAbsent("m", 1, DefaultExpiration) {
+        t.Error("SetIfAbsent over a cached miss = false, want true")
+    }
+    c.SetMiss("m", DefaultExpiration)
+    c.Delete("m")
+    // Only the values stored by Add and SetIfAbsent, each replaced by SetMiss
+    if len(evicted) != 2 {
+        t.Errorf("eviction callbacks called for %v, want [m m]", evicted)
+    }
+}
+
+func TestRegisterTypes(t *testing.T) {
+    // RegisterTypes turns off registering types as Save finds them for the
+    // whole process, which the other tests rely on
+    t.Cleanup(func() { gobTypesDeclared.Store(false) })
+    type declared struct{ X int }
+    type undeclared struct{ Y int }
+    RegisterTypes(declared{})
+
+    c := New(NoExpiration, 0)
+    c.Set("declared", declared{1}, DefaultExpiration)
+    c.Set("int", 2, DefaultExpiration)
+    c.SetMiss("miss", DefaultExpiration)
+    var buf bytes.Buffer
+    if err := c.Save(&buf); err != nil {
+        t.Fatalf("Save: %v", err)
+    }
+    loaded := New(NoExpiration, 0)
+    if err := loaded.Load(&buf); err != nil {
+        t.Fatalf("Load: %v", err)
+    }
+    if x, _ := loaded.Get("declared"); x != (declared{1}) {
+        t.Errorf("declared = %v, want {1}", x)
+    }
+    if _, isMiss, _ := loaded.GetEntry("miss"); !isMiss {
+        t.Error("cached miss not restored")
+    }
+
+    c.Set("bad", undeclared{3}, DefaultExpiration)
+    err := c.Save(&buf)
+    if err == nil || !strings.Contains(err.Error(), "bad") {
+        t.Errorf("Save with an undeclared type returned %v, want an error naming the key", err)
+    }
+}
+
+func TestSampledLRUMaxBytes(t *testing.T) {
+    size := func(x interface{}) int64 { return int64(len(x.(string))) }
+    c := New(NoExpiration, 0, WithMaxBytes(10, size), WithEvictionPolicy(SampledLRU{Samples: 10}))
+    if c.recordsReads() {
+        t.Fatal("SampledLRU makes every read take the write lock")
+    }
+    var events []evictEvent
+    c.OnEvictedReason(func(k string, v interface{}, reason EvictReason) {
+        events = append(events, evictEvent{k, v, reason})
+    })
+    c.Set("a", "aaa", DefaultExpiration)
+    c.Set("b", "bbb", DefaultExpiration)
+    c.Set("c", "ccc", DefaultExpiration)
+    time.Sleep(time.Millisecond)
+    // Leave c the least recently used, so it would be chosen if it weren't
+    // the key being stored
+    c.Get("a")
+    c.Get("b")
+    c.mu.Lock()
+    c.applyReads()
+    c.mu.Unlock()
+    if last := c.Items()["b"].LastAccess; last == 0 {
+        t.Error("reads didn't set LastAccess")
+    }
+
+    c.Set("c", "cccccccc", DefaultExpiration)
+    if x, _ := c.Get("c"); x != "cccccccc" {
+        t.Errorf("c = %v, want cccccccc", x)
+    }
+    if n, approx := c.ItemCount(), c.ApproxItemCount(); n != 1 || approx != 1 {
+        t.Errorf("ItemCount = %d, ApproxItemCount = %d; want 1 and 1", n, approx)
+    }
+    if c.bytes > 10 {
+        t.Errorf("cache holds %d bytes, over its limit of 10", c.bytes)
+    }
+    for _, e := range events {
+        if e.key == "c" && e.reason != EvictReplaced {
+            t.Errorf("c evicted as %v while being stored", e.reason)
+        }
+    }
+}
+
+func TestGetOrLoadLeaderCancelled(t *testing.T) {
+    c := New(NoExpiration, 0)
+    ctx, cancel := context.WithCancel(context.Background())
+    started := make(chan struct{})
+    leaderErr := make(chan error)
+    go func() {
+        _, err := c.GetOrLoadContext(ctx, "k", DefaultExpiration, func(ctx context.Context) (interface{}, error) {
+            close(started)
+            <-ctx.Done()
+            return nil, ctx.Err()
+        })
+        leaderErr <- err
+    }()
+    <-started
+
+    waiter := make(chan error)
+    go func() {
+        v, err := c.GetOrLoad("k", DefaultExpiration, func() (interface{}, error) {
+            return "waiter", nil
+        })
+        if err == nil && v != "waiter" {
+            err = fmt.Errorf("got %v, want waiter", v)
+        }
+        waiter <- err
+    }()
+    // Give the waiter time to start waiting for the leader's load
+    time.Sleep(10 * time.Millisecond)
+    cancel()
+
+    if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+        t.Errorf("leader returned %v, want context.Canceled", err)
+    }
+    if err := <-waiter; err != nil {
+        t.Errorf("waiter: %v", err)
+    }
+}
+
+func TestMaxConcurrentLoadsLeaderTimeout(t *testing.T) {
+    c := New(NoExpiration, 0, WithMaxConcurrentLoads(1))
+    release := make(chan struct{})
+    started := make(chan struct{})
+    go c.GetOrLoad("busy", DefaultExpiration, func() (interface{}, error) {
+        close(started)
+        <-release
+        return "busy", nil
+    })
+    <-started
+
+    ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+    defer cancel()
+    leaderErr := make(chan error)
+    go func() {
+        _, err := c.GetOrLoadContext(ctx, "k", DefaultExpiration, func(context.Context) (interface{}, error) {
+            return "leader", nil
+        })
+        leaderErr <- err
+    }()
+    // Let the leader start waiting for the slot before the waiter comes
+    time.Sleep(5 * time.Millisecond)
+    waiter := make(chan error)
+    go func() {
+        v, err := c.GetOrLoad("k", DefaultExpiration, func() (interface{}, error) {
+            return "waiter", nil
+        })
+        if err == nil && v != "waiter" {
+            err = fmt.Errorf("got %v, want waiter", v)
+        }
+        waiter <- err
+    }()
+
+    if err := <-leaderErr; !errors.Is(err, context.DeadlineExceeded) {
+        t.Errorf("leader returned %v, want context.DeadlineExceeded", err)
+    }
+    close(release)
+    if err := <-waiter; err != nil {
+        t.Errorf("waiter: %v", err)
+    }
+}
+
+func TestKeyValidatorInsertionPaths(t *testing.T) {
+    bad := errors.New("bad key")
+    var mu sync.Mutex
+    var reported int
+    c := New(NoExpiration, 0,
+        WithKeyValidator(func(k string) error {
+            if strings.HasPrefix(k, "bad") {
+                return bad
+            }
+            return nil
+        }),
+        WithErrorHandler(func(err error) {
+            if errors.Is(err, bad) {
+                mu.Lock()
+                reported++
+                mu.Unlock()
+            }
+        }))
+    c.Set("good", 1, DefaultExpiration)
+
+    writes := map[string]func(){
+        "SetMany":               func() { c.SetMany(map[string]interface{}{"bad1": 1}, DefaultExpiration) },
+        "SetManyReturningOld":   func() { c.SetManyReturningOld(map[string]interface{}{"bad2": 1}, DefaultExpiration) },
+        "SetManyWithExpiration": func() { c.SetManyWithExpiration(map[string]Item{"bad3": {Object: 1}}) },
+        "SetIfAbsent":           func() { c.SetIfAbsent("bad4", 1, DefaultExpiration) },
+        "GetOrSet":              func() { c.GetOrSet("bad5", 1, DefaultExpiration) },
+        "GetSet":                func() { c.GetSet("bad6", 1, DefaultExpiration) },
+        "Update": func() {
+            c.Update("bad7", func(interface{}, bool) (interface{}, bool) { return 1, true })
+        },
+        "IncrementMany":   func() { c.IncrementMany(map[string]int64{"bad8": 1}) },
+        "RenameKey":       func() { c.Set("good2", 1, DefaultExpiration); c.RenameKey("good2", "bad9") },
+        "CopyKey":         func() { c.CopyKey("good", "bad10", DefaultExpiration) },
+        "SetWithIdle":     func() { c.SetWithIdle("bad11", 1, time.Minute) },
+        "SetWithDeadline": func() { c.SetWithDeadline("bad12", 1, time.Now().Add(time.Minute)) },
+    }
+    for name, write := range writes {
+        mu.Lock()
+        before := reported
+        mu.Unlock()
+        write()
+        mu.Lock()
+        got := reported - before
+        mu.Unlock()
+        if got != 1 {
+            t.Errorf("%s: reported %d key errors, want 1", name, got)
+        }
+    }
+    for _, k := range c.Keys() {
+        if strings.HasPrefix(k, "bad") {
+            t.Errorf("invalid key %q was stored", k)
+        }
+    }
+    if _, found := c.Get("good2"); !found {
+        t.Error("RenameKey to an invalid key removed the source")
+    }
+
+    err := c.SetManyChecked(map[string]interface{}{"ok": 1, "bad13": 2}, DefaultExpiration)
+    if !errors.Is(err, bad) {
+        t.Errorf("SetManyChecked = %v, want %v", err, bad)
+    }
+    if _, found := c.Get("ok"); found {
+        t.Error("SetManyChecked stored items despite an invalid key")
+    }
+}
+
+func TestNewShardedRejectsStore(t *testing.T) {
+    defer func() {
+        if recover() == nil {
+            t.Error("NewSharded accepted WithStore")
+        }
+    }()
+    NewSharded(NoExpiration, 0, 4, WithStore(wrappedStore{mapStore{}}))
+}
+
+type wrappedStore struct{ Store }
+
+func TestMaxPendingExpired(t *testing.T) {
+    const n = 1000
+    clock := NewFakeClock(time.Unix(0, 0))
+    c := New(NoExpiration, 0, WithClock(clock), WithMaxPendingExpired(n))
+    for i := 0; i < n; i++ {
+        c.Set(strconv.Itoa(i), i, time.Minute)
+    }
+    clock.Advance(2 * time.Minute)
+    sets := 0
+    for c.ItemCount() != 1 && sets <= n/pendingExpiredBudget {
+        c.Set("live", 0, NoExpiration)
+        sets++
+    }
+    if got := c.ItemCount(); got != 1 {
+        t.Errorf("after %d Sets the cache holds %d items, want 1", sets, got)
+    }
+}
+
+func TestCloneKeepsOptions(t *testing.T) {
+    validate := func(k string) error { return nil }
+    c := New(NoExpiration, time.Hour,
+        WithExpirationJitter(0.1),
+        WithWriteThrough(func(string, interface{}, time.Duration) error { return nil }, nil),
+        WithMaxKeyLength(8),
+        WithKeyValidator(validate),
+        WithRefreshAhead(time.Second, func(string) (interface{}, time.Duration, error) { return nil, 0, nil }),
+        WithEvictionLog(4),
+        WithLazyExpiration(false),
+        WithPreciseExpiration(),
+        WithMaxPendingExpired(10),
+        WithIncrementalCleanup(time.Millisecond),
+        WithMaxItems(2))
+    defer c.Close()
+    c.Set("a", 1, DefaultExpiration)
+    c.Set("b", 2, DefaultExpiration)
+    c.Get("a")
+
+    clone := c.Clone()
+    defer clone.Close()
+    switch {
+    case clone.jitter != 0.1:
+        t.Error("jitter not copied")
+    case clone.writeSet == nil:
+        t.Error("write-through not copied")
+    case clone.maxKeyLength != 8:
+        t.Error("key length limit not copied")
+    case clone.keyValidator == nil:
+        t.Error("key validator not copied")
+    case clone.refresh == nil || clone.refreshWindow != time.Second:
+        t.Error("refresh-ahead not copied")
+    case clone.evictionLog == nil || len(clone.evictionLog.records) != 4:
+        t.Error("eviction log not copied")
+    case !clone.janitorExpiresOnly:
+        t.Error("lazy expiration setting not copied")
+    case !clone.precise:
+        t.Error("precise expiration not copied")
+    case clone.maxPendingExpired != 10:
+        t.Error("max pending expired not copied")
+    case clone.cleanupBudget != time.Millisecond:
+        t.Error("incremental cleanup not copied")
+    }
+
+    // b is least recently used in c, so the clone must evict it first
+    clone.Set("c", 3, DefaultExpiration)
+    if _, found := clone.Get("b"); found {
+        t.Error("clone lost c's eviction order")
+    }
+}
+
+func TestTieredUsesL2Clock(t *testing.T) {
+    clock := NewFakeClock(time.Unix(0, 0))
+    l1 := New(NoExpiration, 0)
+    l2 := New(NoExpiration, 0, WithClock(clock))
+    tiered := NewTiered(l1, l2, NoExpiration)
+    l2.Set("k", 1, time.Minute)
+    if _, found := tiered.Get("k"); !found {
+        t.Fatal("Get didn't find k in L2")
+    }
+    ttl, found := l1.TTL("k")
+    if !found {
+        t.Fatal("k wasn't promoted to L1")
+    }
+    if ttl <= 0 || ttl > time.Minute {
+        t.Errorf("L1 TTL = %v, want up to %v", ttl, time.Minute)
+    }
+}
+
+func TestShardedSaveLoad(t *testing.T) {
+    sc := NewSharded(NoExpiration, 0, 4, WithPreciseExpiration())
+    for i := 0; i < 100; i++ {
+        sc.Set(strconv.Itoa(i), i, time.Hour)
+    }
+    var buf bytes.Buffer
+    if err := sc.Save(&buf); err != nil {
+        t.Fatal(err)
+    }
+    dump := buf.Bytes()
+
+    loaded := NewSharded(NoExpiration, 0, 3)
+    if err := loaded.Load(bytes.NewReader(dump)); err != nil {
+        t.Fatal(err)
+    }
+    c := New(NoExpiration, 0)
+    if err := c.Load(bytes.NewReader(dump)); err != nil {
+        t.Fatal(err)
+    }
+    for i := 0; i < 100; i++ {
+        k := strconv.Itoa(i)
+        if v, found := loaded.Get(k); !found || v != i {
+            t.Errorf("Sharded.Load: %s = %v, %v; want %d", k, v, found, i)
+        }
+        if v, found := c.Get(k); !found || v != i {
+            t.Errorf("Cache.Load: %s = %v, %v; want %d", k, v, found, i)
+        }
+    }
+
+    sc.Close()
+    for i, shard := range sc.shards {
+        shard.mu.RLock()
+        armed := shard.expirations.onChange != nil
+        shard.mu.RUnlock()
+        if armed {
+            t.Errorf("shard %d still has its expiration timer after Close", i)
+        }
+    }
+}
+
+// missOnceStore misses the first lookup of each key, as if another call had
+// stored it just after.
+type missOnceStore struct {
+    Store
+    seen map[string]bool
+}
+
+func (s *missOnceStore) Get(k string) (Item, bool) {
+    if !s.seen[k] {
+        s.seen[k] = true
+        return Item{}, false
+    }
+    return s.Store.Get(k)
+}
+
+func TestGetOrLoadRecheckWithRefreshAhead(t *testing.T) {
+    store := &missOnceStore{Store: mapStore{}, seen: map[string]bool{}}
+    c := New(NoExpiration, 0, WithStore(store),
+        WithRefreshAhead(time.Hour, func(string) (interface{}, time.Duration, error) {
+            return 2, time.Minute, nil
+        }))
+    c.mu.Lock()
+    c.items.Set("k", Item{Object: 1, Expiration: c.now() + int64(time.Minute)})
+    c.mu.Unlock()
+
+    done := make(chan interface{})
+    go func() {
+        v, _ := c.GetOrLoad("k", time.Minute, func() (interface{}, error) { return 3, nil })
+        done <- v
+    }()
+    select {
+    case v := <-done:
+        if v != 1 {
+            t.Errorf("GetOrLoad = %v, want 1", v)
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("GetOrLoad deadlocked")
+    }
+}
+
+func TestGetOrLoadCountsOneMiss(t *testing.T) {
+    c := New(NoExpiration, 0, WithStats())
+    c.GetOrLoad("k", NoExpiration, func() (interface{}, error) { return 1, nil })
+    if got := c.Stats().Misses; got != 1 {
+        t.Errorf("Misses = %d, want 1", got)
+    }
+}
+
+func TestSetMissSkipsSizer(t *testing.T) {
+    errs := make(chan error, 1)
+    c := New(NoExpiration, 0,
+        WithMaxBytes(100, func(x interface{}) int64 { return int64(len(x.(string))) }),
+        WithErrorHandler(func(err error) { errs <- err }))
+    c.SetMiss("miss", NoExpiration)
+    c.Set("k", "v", NoExpiration)
+    if _, found := c.Get("k"); !found {
+        t.Error("k not stored after SetMiss")
+    }
+    c.mu.RLock()
+    used := c.bytes
+    c.mu.RUnlock()
+    if used != 1 {
+        t.Errorf("bytes = %d, want 1", used)
+    }
+
+    // A panicking sizer is reported, and leaves the cache usable
+    c.Set("bad", 1, NoExpiration)
+    select {
+    case err := <-errs:
+        if !strings.Contains(err.Error(), "sizer for bad panicked") {
+            t.Errorf("error = %v", err)
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("sizer panic not reported")
+    }
+    if _, found := c.Get("bad"); !found {
+        t.Error("bad not stored")
+    }
+}
+
+func TestWriteThroughPaths(t *testing.T) {
+    var mu sync.Mutex
+    sets := map[string]interface{}{}
+    deletes := map[string]bool{}
+    c := New(NoExpiration, 0, WithWriteThrough(
+        func(k string, v interface{}, d time.Duration) error {
+            mu.Lock()
+            sets[k] = v
+            mu.Unlock()
+            return nil
+        },
+        func(k string) error {
+            mu.Lock()
+            deletes[k] = true
+            mu.Unlock()
+            return nil
+        }))
+
+    c.Add("add", 1, DefaultExpiration)
+    c.Set("replace", 0, DefaultExpiration)
+    c.Replace("replace", 2, DefaultExpiration)
+    c.SetIfAbsent("ifabsent", 3, DefaultExpiration)
+    c.GetSet("getset", 4, DefaultExpiration)
+    c.GetOrSet("getorset", 5, DefaultExpiration)
+    c.Update("update", func(interface{}, bool) (interface{}, bool) { return 6, true })
+    c.SetMany(map[string]interface{}{"many": 7}, DefaultExpiration)
+    c.IncrementMany(map[string]int64{"incr": 8})
+    c.Set("rename", 9, DefaultExpiration)
+    c.RenameKey("rename", "renamed")
+    c.GetAndDelete("add")
+    c.DeleteMany([]string{"replace"})
+    c.DeleteByPrefix("ifabsent")
+    c.RemoveIf(func(k string, _ Item) bool { return k == "getset" })
+
+    c.SetMiss("miss", NoExpiration)
+    c.GetOrLoad("loaded", NoExpiration, func() (interface{}, error) { return 10, nil })
+
+    mu.Lock()
+    defer mu.Unlock()
+    want := map[string]interface{}{
+        "add": 1, "replace": 2, "ifabsent": 3, "getset": 4, "getorset": 5,
+        "update": 6, "many": 7, "incr": int64(8), "rename": 9, "renamed": 9,
+    }
+    for k, v := range want {
+        if sets[k] != v {
+            t.Errorf("onSet(%s) = %v, want %v", k, sets[k], v)
+        }
+    }
+    for _, k := range []string{"miss", "loaded"} {
+        if _, ok := sets[k]; ok {
+            t.Errorf("onSet called for %s", k)
+        }
+    }
+    for _, k := range []string{"add", "replace", "ifabsent", "getset", "rename"} {
+        if !deletes[k] {
+            t.Errorf("onDelete not called for %s", k)
+        }
+    }
+}
+
+func TestAddOnEvictedConcurrent(t *testing.T) {
+    c := New(NoExpiration, 0)
+    var wg sync.WaitGroup
+    wg.Add(2)
+    go func() {
+        defer wg.Done()
+        for i := 0; i < 100; i++ {
+            c.Set("k", i, DefaultExpiration)
+            c.Delete("k")
+            runtime.Gosched()
+        }
+    }()
+    go func() {
+        defer wg.Done()
+        for i := 0; i < 100; i++ {
+            c.AddOnEvicted(func(string, interface{}) {})
+            c.OnEvictedReason(func(string, interface{}, EvictReason) {})
+            runtime.Gosched()
+        }
+    }()
+    wg.Wait()
+}
+
+func TestOverwriteKeepsEvictionRank(t *testing.T) {
+    size := func(x interface{}) int64 { return int64(len(x.(string))) }
+    for _, policy := range []EvictionPolicy{LRU, LFU} {
+        c := New(NoExpiration, 0, WithMaxBytes(4, size), WithEvictionPolicy(policy))
+        c.Set("hot", "a", NoExpiration)
+        c.Set("cold", "b", NoExpiration)
+        for i := 0; i < 100; i++ {
+            c.Get("hot")
+        }
+        c.Get("cold")
+        c.Set("other", "c", NoExpiration)
+        for i := 0; i < 10; i++ {
+            c.Get("other")
+        }
+        // Overwriting hot with a larger value must evict something else
+        c.Set("hot", "aaa", NoExpiration)
+        if _, found := c.Get("hot"); !found {
+            t.Errorf("%T: hot evicted to make room for itself", policy)
+        }
+        if _, found := c.Get("cold"); found {
+            t.Errorf("%T: cold kept over hot", policy)
+        }
+        // hot keeps its rank, so the next eviction takes other
+        c.Set("new", "d", NoExpiration)
+        if _, found := c.Get("hot"); !found {
+            t.Errorf("%T: hot lost its rank when overwritten", policy)
+        }
+    }
+}
+
+func TestLoadingCloseStopsNegativeCache(t *testing.T) {
+    l := NewLoading(New(NoExpiration, time.Hour), func(string) (interface{}, time.Duration, error) {
+        return nil, 0, errors.New("not found")
+    }, WithNegativeCaching(time.Hour))
+    l.Close()
+    l.negative.janitorMu.Lock()
+    running := l.negative.janitor != nil
+    l.negative.janitorMu.Unlock()
+    if running {
+        t.Error("negative cache's janitor still running after Close")
+    }
+}
+
+func TestLazyExpirationOffReads(t *testing.T) {
+    clock := NewFakeClock(time.Unix(0, 0))
+    c := New(NoExpiration, 0, WithClock(clock), WithLazyExpiration(false))
+    c.Set("k", 1, time.Minute)
+    clock.Advance(2 * time.Minute)
+
+    if _, found := c.Get("k"); !found {
+        t.Error("Get missed k")
+    }
+    if _, found := c.Peek("k"); !found {
+        t.Error("Peek missed k")
+    }
+    if _, _, found := c.GetWithExpiration("k"); !found {
+        t.Error("GetWithExpiration missed k")
+    }
+    if _, found := c.GetMany([]string{"k"})["k"]; !found {
+        t.Error("GetMany missed k")
+    }
+    if _, _, found := c.GetEntry("k"); !found {
+        t.Error("GetEntry missed k")
+    }
+    if _, found := c.TTL("k"); !found {
+        t.Error("TTL missed k")
+    }
+    c.DeleteExpired()
+    if _, found := c.Get("k"); found {
+        t.Error("Get found k after DeleteExpired")
+    }
+}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+    "sync"
+    "time"
+)
+
+type Clock interface {
+    Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+    return time.Now()
+}
+
+// FakeClock is a Clock that only moves when told to, for testing expiration
+// without sleeping.
+type FakeClock struct {
+    mu  sync.Mutex
+    now time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+    return &FakeClock{now: now}
+}
+
+func (f *FakeClock) Now() time.Time {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return f.now
+}
+
+func (f *FakeClock) Advance(d time.Duration) {
+    f.mu.Lock()
+    f.now = f.now.Add(d)
+    f.mu.Unlock()
+}
+
+func (f *FakeClock) Set(now time.Time) {
+    f.mu.Lock()
+    f.now = now
+    f.mu.Unlock()
+}
+
+func (c *cache) now() int64 {
+    return c.clock.Now().UnixNano()
+}
+
+func (c *cache) expired(item Item) bool {
+    return item.Expiration > 0 && c.now() > item.Expiration
+}
+
+// readExpired is expired for the reads and lookups of single keys, which
+// WithLazyExpiration(false) makes find items until the janitor deletes them.
+func (c *cache) readExpired(item Item) bool {
+    return !c.janitorExpiresOnly && c.expired(item)
+}
@@ -0,0 +1,93 @@
+package cache
+
+import "time"
+
+// Clone returns a new cache holding copies of c's items, with the same
+// options but its own lock and janitor. The items' values themselves are
+// shared, not copied, so mutable values are seen by both caches. Not copied
+// are eviction callbacks, subscribers and watchers, the store passed to
+// WithStore, as the clone keeps its items in a map, and the contents of the
+// WithEvictionLog log and of WithStats: the clone starts with an empty log of
+// the same size and zeroed statistics.
+func (c *Cache) Clone() *Cache {
+    c.janitorMu.Lock()
+    var ci time.Duration
+    if c.janitor != nil {
+        ci = c.janitor.Interval
+    }
+    c.janitorMu.Unlock()
+
+    // Held for writing so the buffered reads reach the evictor it copies
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if c.evictor != nil {
+        c.applyReads()
+    }
+    items := c.snapshot()
+    opts := []Option{
+        WithDefaultExpiration(c.DefaultExpiration()),
+        WithCleanupInterval(ci),
+        WithClock(c.clock),
+        WithErrorHandler(c.onError),
+    }
+    if c.stats != nil {
+        opts = append(opts, WithStats())
+    }
+    if c.stats.recordsLatency() {
+        opts = append(opts, WithLatencyStats())
+    }
+    if c.evictor != nil {
+        opts = append(opts, WithMaxItems(c.maxItems), WithEvictionPolicy(c.policy))
+    }
+    if c.sizer != nil {
+        opts = append(opts, WithMaxBytes(c.maxBytes, c.sizer))
+    }
+    if c.trackAccess {
+        opts = append(opts, WithAccessTracking())
+    }
+    if c.loadSem != nil {
+        opts = append(opts, WithMaxConcurrentLoads(cap(c.loadSem)))
+    }
+    if c.adaptiveTTL != nil {
+        opts = append(opts, WithAdaptiveTTL(c.adaptiveTTL))
+    }
+    if c.jitter != 0 {
+        opts = append(opts, WithExpirationJitter(c.jitter))
+    }
+    if c.writeSet != nil || c.writeDelete != nil {
+        opts = append(opts, WithWriteThrough(c.writeSet, c.writeDelete))
+    }
+    if c.maxKeyLength > 0 {
+        opts = append(opts, WithMaxKeyLength(c.maxKeyLength))
+    }
+    if c.keyValidator != nil {
+        opts = append(opts, WithKeyValidator(c.keyValidator))
+    }
+    if c.refresh != nil {
+        opts = append(opts, WithRefreshAhead(c.refreshWindow, c.refresh))
+    }
+    if c.evictionLog != nil {
+        opts = append(opts, WithEvictionLog(len(c.evictionLog.records)))
+    }
+    if c.janitorExpiresOnly {
+        opts = append(opts, WithLazyExpiration(false))
+    }
+    if c.precise {
+        opts = append(opts, WithPreciseExpiration())
+    }
+    if c.maxPendingExpired > 0 {
+        opts = append(opts, WithMaxPendingExpired(c.maxPendingExpired))
+    }
+    if c.cleanupBudget > 0 {
+        opts = append(opts, WithIncrementalCleanup(c.cleanupBudget))
+    }
+    clone := newCache(items, opts...)
+    if c.evictor != nil {
+        // Keep c's eviction order; the precise-expiration timer may already
+        // be running, so take the lock
+        clone.mu.Lock()
+        clone.evictor = c.evictor.clone()
+        clone.mu.Unlock()
+    }
+    return startJanitor(clone)
+}
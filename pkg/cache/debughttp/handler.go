@@ -0,0 +1,127 @@
+// Package debughttp serves a cache's stats and items over HTTP for
+// debugging, kept apart from package cache so it doesn't depend on net/http.
+package debughttp
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "sort"
+    "time"
+
+    "github.com/d3code/xcache/pkg/cache"
+)
+
+type handler struct {
+    c          *cache.Cache
+    showValues bool
+}
+
+// DebugHandler returns a handler serving JSON about c at these paths, relative
+// to wherever it is mounted, for example with http.StripPrefix:
+//
+//	GET /stats            the item count and Stats
+//	GET /keys             the unexpired keys, sorted
+//	GET /item?key=k       how k was found, its time to live and value type
+//	DELETE /item?key=k    deletes k
+//
+// Values are only included in /item if showValues is true, as they may hold
+// secrets; they must then encode as JSON. Looking items up doesn't count as
+// reading them.
+func DebugHandler(c *cache.Cache, showValues bool) http.Handler {
+    h := &handler{c: c, showValues: showValues}
+    mux := http.NewServeMux()
+    mux.HandleFunc("/stats", h.stats)
+    mux.HandleFunc("/keys", h.keys)
+    mux.HandleFunc("/item", h.item)
+    return mux
+}
+
+func (h *handler) stats(w http.ResponseWriter, r *http.Request) {
+    if !allow(w, r, http.MethodGet) {
+        return
+    }
+    s := h.c.Stats()
+    writeJSON(w, map[string]interface{}{
+        "items":       h.c.ItemCount(),
+        "hits":        s.Hits,
+        "misses":      s.Misses,
+        "hitRatio":    s.HitRatio(),
+        "evictions":   s.Evictions,
+        "expirations": s.Expirations,
+        "dropped":     s.Dropped,
+    })
+}
+
+func (h *handler) keys(w http.ResponseWriter, r *http.Request) {
+    if !allow(w, r, http.MethodGet) {
+        return
+    }
+    keys := h.c.Keys()
+    sort.Strings(keys)
+    writeJSON(w, keys)
+}
+
+type itemInfo struct {
+    Key   string `json:"key"`
+    State string `json:"state"`
+    // TTL is empty for items that don't expire
+    TTL   string      `json:"ttl,omitempty"`
+    Type  string      `json:"type,omitempty"`
+    Value interface{} `json:"value,omitempty"`
+}
+
+func (h *handler) item(w http.ResponseWriter, r *http.Request) {
+    if !allow(w, r, http.MethodGet, http.MethodDelete) {
+        return
+    }
+    k := r.URL.Query().Get("key")
+    if k == "" {
+        http.Error(w, "missing key parameter", http.StatusBadRequest)
+        return
+    }
+    if r.Method == http.MethodDelete {
+        h.c.Delete(k)
+        w.WriteHeader(http.StatusNoContent)
+        return
+    }
+    v, state := h.c.Lookup(k)
+    info := itemInfo{Key: k, State: state.String()}
+    if state != cache.LookupMiss {
+        info.Type = fmt.Sprintf("%T", v)
+        if ttl, found := h.c.TTL(k); found && ttl != cache.NoExpiration {
+            info.TTL = ttl.Round(time.Millisecond).String()
+        }
+        if h.showValues {
+            info.Value = v
+        }
+    }
+    writeJSON(w, info)
+}
+
+// allow reports whether r uses one of methods, replying with
+// http.StatusMethodNotAllowed if it doesn't.
+func allow(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+    for _, m := range methods {
+        if r.Method == m {
+            return true
+        }
+    }
+    for _, m := range methods {
+        w.Header().Add("Allow", m)
+    }
+    http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+    return false
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+    // Encode before writing anything so an encoding failure can still be
+    // reported as an error status
+    b, err := json.Marshal(v)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.Write(append(b, '\n'))
+}
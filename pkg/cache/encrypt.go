@@ -0,0 +1,79 @@
+package cache
+
+import (
+    "bytes"
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/rand"
+    "fmt"
+    "io"
+    "os"
+)
+
+// encryptedMagic starts files written by SaveFileEncrypted, followed by the
+// nonce and the sealed gob stream.
+var encryptedMagic = []byte("XCENC1")
+
+// SaveFileEncrypted saves the cache to name like SaveFile, encrypted with
+// AES-GCM. key must be 16, 24 or 32 bytes long, for AES-128, AES-192 or
+// AES-256.
+func (c *cache) SaveFileEncrypted(name string, key []byte) error {
+    gcm, err := newGCM(key)
+    if err != nil {
+        return err
+    }
+    var buf bytes.Buffer
+    if err := c.Save(&buf); err != nil {
+        return err
+    }
+    nonce := make([]byte, gcm.NonceSize())
+    if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+        return err
+    }
+    return writeFileAtomic(name, func(w io.Writer) error {
+        header := append(append([]byte{}, encryptedMagic...), nonce...)
+        if _, err := w.Write(header); err != nil {
+            return err
+        }
+        _, err := w.Write(gcm.Seal(nil, nonce, buf.Bytes(), header))
+        return err
+    })
+}
+
+// LoadFileEncrypted loads a file written by SaveFileEncrypted with the same
+// key. Nothing is loaded if the file can't be authenticated.
+func (c *cache) LoadFileEncrypted(name string, key []byte) error {
+    data, err := os.ReadFile(name)
+    if err != nil {
+        return err
+    }
+    plain, err := decrypt(data, key)
+    if err != nil {
+        return fmt.Errorf("loading %s: %w", name, err)
+    }
+    return c.Load(bytes.NewReader(plain))
+}
+
+func decrypt(data, key []byte) ([]byte, error) {
+    gcm, err := newGCM(key)
+    if err != nil {
+        return nil, err
+    }
+    n := len(encryptedMagic) + gcm.NonceSize()
+    if len(data) < n || !bytes.Equal(data[:len(encryptedMagic)], encryptedMagic) {
+        return nil, fmt.Errorf("not an encrypted cache file")
+    }
+    plain, err := gcm.Open(nil, data[len(encryptedMagic):n], data[n:], data[:n])
+    if err != nil {
+        return nil, fmt.Errorf("authentication failed: wrong key or corrupted file")
+    }
+    return plain, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+    block, err := aes.NewCipher(key)
+    if err != nil {
+        return nil, err
+    }
+    return cipher.NewGCM(block)
+}
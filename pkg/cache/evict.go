@@ -0,0 +1,87 @@
+package cache
+
+import "fmt"
+
+type EvictReason int
+
+const (
+    EvictDeleted EvictReason = iota
+    EvictExpired
+    EvictReplaced
+    EvictOverflowed
+)
+
+func (r EvictReason) String() string {
+    switch r {
+    case EvictDeleted:
+        return "deleted"
+    case EvictExpired:
+        return "expired"
+    case EvictReplaced:
+        return "replaced"
+    case EvictOverflowed:
+        return "overflowed"
+    }
+    return "unknown"
+}
+
+// OnEvictedReason sets a callback that, unlike OnEvicted, is told why the item
+// left the cache. Both callbacks may be set at once.
+func (c *cache) OnEvictedReason(f func(string, interface{}, EvictReason)) {
+    c.mu.Lock()
+    cb := c.evictCallbacks()
+    cb.onEvictedReason = f
+    c.callbacks.Store(&cb)
+    c.mu.Unlock()
+}
+
+// evictCallbacks holds the callbacks set with OnEvicted, AddOnEvicted and
+// OnEvictedReason. They're replaced as a whole, under c.mu, and read
+// atomically, as notifyEvicted runs once c.mu is released.
+type evictCallbacks struct {
+    onEvicted       []func(string, interface{})
+    onEvictedReason func(string, interface{}, EvictReason)
+}
+
+// evictCallbacks returns a copy of the current callbacks.
+func (c *cache) evictCallbacks() evictCallbacks {
+    if cb := c.callbacks.Load(); cb != nil {
+        return *cb
+    }
+    return evictCallbacks{}
+}
+
+func (c *cache) notifiesEvictions() bool {
+    cb := c.evictCallbacks()
+    return len(cb.onEvicted) > 0 || cb.onEvictedReason != nil || c.evictionLog != nil || c.hasSubscribers()
+}
+
+func (c *cache) notifyEvicted(evicted []keyAndValue) {
+    evicted = withoutMisses(evicted)
+    if len(evicted) > 0 {
+        if c.evictionLog != nil {
+            c.logEvicted(evicted)
+        }
+        c.publish(evicted)
+    }
+    cb := c.evictCallbacks()
+    for _, v := range evicted {
+        for _, f := range cb.onEvicted {
+            c.callEvicted(v, func() { f(v.key, v.value) })
+        }
+        if cb.onEvictedReason != nil {
+            c.callEvicted(v, func() { cb.onEvictedReason(v.key, v.value, v.reason) })
+        }
+    }
+}
+
+// callEvicted runs an eviction callback, passing a panic from it to the error
+// handler instead of letting it escape into the janitor or the caller.
+func (c *cache) callEvicted(v keyAndValue, f func()) {
+    defer func() {
+        if r := recover(); r != nil {
+            c.handleError(fmt.Errorf("eviction callback for %s panicked: %v", v.key, r))
+        }
+    }()
+    f()
+}
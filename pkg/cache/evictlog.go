@@ -0,0 +1,55 @@
+package cache
+
+import (
+    "sync"
+    "time"
+)
+
+// EvictionRecord describes an item that left the cache, without its value.
+type EvictionRecord struct {
+    Key    string
+    Reason EvictReason
+    Time   time.Time
+}
+
+// evictionLog keeps the most recent records in a ring.
+type evictionLog struct {
+    mu      sync.Mutex
+    records []EvictionRecord
+    next    int
+    full    bool
+}
+
+func (l *evictionLog) add(r EvictionRecord) {
+    l.mu.Lock()
+    l.records[l.next] = r
+    l.next = (l.next + 1) % len(l.records)
+    if l.next == 0 {
+        l.full = true
+    }
+    l.mu.Unlock()
+}
+
+// RecentEvictions returns the records kept by a cache created
+// WithEvictionLog, oldest first. Replaced items aren't recorded.
+func (c *cache) RecentEvictions() []EvictionRecord {
+    l := c.evictionLog
+    if l == nil {
+        return nil
+    }
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if !l.full {
+        return append([]EvictionRecord(nil), l.records[:l.next]...)
+    }
+    return append(append([]EvictionRecord(nil), l.records[l.next:]...), l.records[:l.next]...)
+}
+
+func (c *cache) logEvicted(evicted []keyAndValue) {
+    now := c.clock.Now()
+    for _, v := range evicted {
+        if v.reason != EvictReplaced {
+            c.evictionLog.add(EvictionRecord{v.key, v.reason, now})
+        }
+    }
+}
@@ -0,0 +1,114 @@
+package cache
+
+import "container/heap"
+
+type expEntry struct {
+    key        string
+    expiration int64
+    index      int
+}
+
+type expHeap []*expEntry
+
+func (h expHeap) Len() int           { return len(h) }
+func (h expHeap) Less(i, j int) bool { return h[i].expiration < h[j].expiration }
+
+func (h expHeap) Swap(i, j int) {
+    h[i], h[j] = h[j], h[i]
+    h[i].index = i
+    h[j].index = j
+}
+
+func (h *expHeap) Push(x interface{}) {
+    e := x.(*expEntry)
+    e.index = len(*h)
+    *h = append(*h, e)
+}
+
+func (h *expHeap) Pop() interface{} {
+    old := *h
+    n := len(old)
+    e := old[n-1]
+    old[n-1] = nil
+    *h = old[:n-1]
+    return e
+}
+
+// expirations orders the keys of items that can expire by their expiration
+// time so DeleteExpired only has to visit the items that have expired. Items
+// with no expiration are not tracked.
+type expirations struct {
+    h       expHeap
+    entries map[string]*expEntry
+    // onChange, if set, is called after every change
+    onChange func()
+}
+
+func newExpirations() *expirations {
+    return &expirations{
+        entries: make(map[string]*expEntry),
+    }
+}
+
+// update records that k expires at e, or stops tracking it if e is 0.
+func (x *expirations) update(k string, e int64) {
+    if e <= 0 {
+        x.remove(k)
+        return
+    }
+    if entry, ok := x.entries[k]; ok {
+        entry.expiration = e
+        heap.Fix(&x.h, entry.index)
+    } else {
+        entry := &expEntry{key: k, expiration: e}
+        heap.Push(&x.h, entry)
+        x.entries[k] = entry
+    }
+    x.changed()
+}
+
+func (x *expirations) remove(k string) {
+    if entry, ok := x.entries[k]; ok {
+        heap.Remove(&x.h, entry.index)
+        delete(x.entries, k)
+        x.changed()
+    }
+}
+
+// next returns the key that expires soonest.
+func (x *expirations) next() (string, int64, bool) {
+    if len(x.h) == 0 {
+        return "", 0, false
+    }
+    return x.h[0].key, x.h[0].expiration, true
+}
+
+// countExpired returns how many keys expire before now, counting no further
+// than limit. It only visits those keys, as a key's children in the heap
+// expire no sooner than it does.
+func (x *expirations) countExpired(now int64, limit int) int {
+    n := 0
+    stack := []int{0}
+    for len(stack) > 0 && n < limit {
+        i := stack[len(stack)-1]
+        stack = stack[:len(stack)-1]
+        if i >= len(x.h) || now <= x.h[i].expiration {
+            continue
+        }
+        n++
+        stack = append(stack, 2*i+1, 2*i+2)
+    }
+    return n
+}
+
+func (x *expirations) reset() {
+    x.h = nil
+    x.entries = make(map[string]*expEntry)
+    x.changed()
+}
+
+func (x *expirations) changed() {
+    if x.onChange != nil {
+        x.onChange()
+    }
+}
@@ -0,0 +1,21 @@
+package cache
+
+import "expvar"
+
+// PublishExpvar publishes the cache's item count and stats under name. The
+// values are read each time the variable is, and the stats are zero unless
+// the cache was created WithStats. Like expvar.Publish, it panics if name is
+// already in use.
+func (c *cache) PublishExpvar(name string) {
+    expvar.Publish(name, expvar.Func(func() interface{} {
+        s := c.Stats()
+        return map[string]interface{}{
+            "items":       c.ItemCount(),
+            "hits":        s.Hits,
+            "misses":      s.Misses,
+            "evictions":   s.Evictions,
+            "expirations": s.Expirations,
+            "dropped":     s.Dropped,
+        }
+    }))
+}
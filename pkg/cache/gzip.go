@@ -0,0 +1,57 @@
+package cache
+
+import (
+    "bufio"
+    "bytes"
+    "compress/gzip"
+    "io"
+    "os"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+func (c *cache) SaveFileCompressed(name string) error {
+    return writeFileAtomic(name, func(w io.Writer) error {
+        zw := gzip.NewWriter(w)
+        if err := c.Save(zw); err != nil {
+            return err
+        }
+        return zw.Close()
+    })
+}
+
+// LoadFileCompressed loads a file written by SaveFileCompressed or SaveFile,
+// telling them apart by the gzip header.
+func (c *cache) LoadFileCompressed(name string) error {
+    fp, err := os.Open(name)
+    if err != nil {
+        return err
+    }
+    err = c.loadMaybeCompressed(fp)
+    if err != nil {
+        errFile := fp.Close()
+        if errFile != nil {
+            return errFile
+        }
+        return err
+    }
+    return fp.Close()
+}
+
+func (c *cache) loadMaybeCompressed(r io.Reader) error {
+    br := bufio.NewReader(r)
+    magic, err := br.Peek(len(gzipMagic))
+    if err != nil || !bytes.Equal(magic, gzipMagic) {
+        // A plain gob stream
+        return c.Load(br)
+    }
+    zr, err := gzip.NewReader(br)
+    if err != nil {
+        return err
+    }
+    err = c.Load(zr)
+    if err != nil {
+        return err
+    }
+    return zr.Close()
+}
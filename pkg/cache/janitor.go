@@ -1,18 +1,25 @@
 package cache
 
-import "time"
+import (
+    "runtime"
+    "time"
+)
 
 type janitor struct {
     Interval time.Duration
     stop     chan bool
 }
 
-func (j *janitor) Run(c *cache) {
+type expirer interface {
+    cleanup()
+}
+
+func (j *janitor) Run(c expirer) {
     ticker := time.NewTicker(j.Interval)
     for {
         select {
         case <-ticker.C:
-            c.DeleteExpired()
+            c.cleanup()
         case <-j.stop:
             ticker.Stop()
             return
@@ -20,11 +27,53 @@ func (j *janitor) Run(c *cache) {
     }
 }
 
+func (c *Cache) Close() {
+    runtime.SetFinalizer(c, nil)
+    c.closeJanitor()
+    c.stopTimer()
+}
+
+// SetCleanupInterval restarts the janitor to delete expired items every d, or
+// stops it if d is zero or less. Nothing changes if the janitor already runs
+// every d.
+func (c *Cache) SetCleanupInterval(d time.Duration) {
+    c.janitorMu.Lock()
+    defer c.janitorMu.Unlock()
+    if c.janitor != nil {
+        if c.janitor.Interval == d {
+            return
+        }
+        c.janitor.stop <- true
+        c.janitor = nil
+    }
+    runtime.SetFinalizer(c, nil)
+    if d > 0 {
+        c.startJanitor(d)
+        runtime.SetFinalizer(c, stopJanitor)
+    }
+}
+
+func (c *cache) closeJanitor() {
+    c.janitorMu.Lock()
+    if c.janitor != nil {
+        c.janitor.stop <- true
+        c.janitor = nil
+    }
+    c.janitorMu.Unlock()
+}
+
 func stopJanitor(c *Cache) {
-    c.janitor.stop <- true
+    c.closeJanitor()
 }
 
 func runJanitor(c *cache, ci time.Duration) {
+    c.janitorMu.Lock()
+    c.startJanitor(ci)
+    c.janitorMu.Unlock()
+}
+
+// startJanitor must be called with c.janitorMu held.
+func (c *cache) startJanitor(ci time.Duration) {
     j := &janitor{
         Interval: ci,
         stop:     make(chan bool),
@@ -0,0 +1,66 @@
+package cache
+
+import (
+    "encoding/json"
+    "io"
+    "time"
+)
+
+type jsonItem struct {
+    Object      interface{}   `json:"object"`
+    Expiration  int64         `json:"expiration"`
+    Idle        time.Duration `json:"idle,omitempty"`
+    Created     int64         `json:"created,omitempty"`
+    LastAccess  int64         `json:"lastAccess,omitempty"`
+    AccessCount int64         `json:"accessCount,omitempty"`
+    BaseTTL     time.Duration `json:"baseTTL,omitempty"`
+    // IsMiss marks an entry stored with SetMiss, which has no object
+    IsMiss bool `json:"isMiss,omitempty"`
+}
+
+// SaveJSON writes the cache's items to w as a JSON object keyed by item key,
+// with each expiration stored as Unix nanoseconds (0 means no expiration) and
+// cached misses marked by isMiss.
+func (c *cache) SaveJSON(w io.Writer) error {
+    c.mu.RLock()
+    items := make(map[string]jsonItem, c.items.Len())
+    c.items.Range(func(k string, v Item) bool {
+        item := jsonItem{v.Object, v.Expiration, v.Idle, v.Created, v.LastAccess, v.AccessCount, v.BaseTTL, false}
+        if v.Object == cachedMiss {
+            item.Object, item.IsMiss = nil, true
+        }
+        items[k] = item
+        return true
+    })
+    c.mu.RUnlock()
+    return json.NewEncoder(w).Encode(items)
+}
+
+// LoadJSON adds the items written by SaveJSON, keeping any live items already
+// in the cache. Values come back as their JSON representation
+// (map[string]interface{}, []interface{}, float64, string, bool or nil), not
+// as the concrete types that were saved.
+func (c *cache) LoadJSON(r io.Reader) error {
+    items := map[string]jsonItem{}
+    if err := json.NewDecoder(r).Decode(&items); err != nil {
+        return err
+    }
+    loaded := make(map[string]Item, len(items))
+    for k, v := range items {
+        item := Item{
+            Object:      v.Object,
+            Expiration:  v.Expiration,
+            Idle:        v.Idle,
+            Created:     v.Created,
+            LastAccess:  v.LastAccess,
+            AccessCount: v.AccessCount,
+            BaseTTL:     v.BaseTTL,
+        }
+        if v.IsMiss {
+            item.Object = cachedMiss
+        }
+        loaded[k] = item
+    }
+    c.loadItems(loaded)
+    return nil
+}
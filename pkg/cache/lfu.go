@@ -0,0 +1,123 @@
+package cache
+
+import "container/heap"
+
+type lfuPolicy struct{}
+
+func (lfuPolicy) newEvictor() evictor {
+    return newLFU()
+}
+
+type lfuEntry struct {
+    key   string
+    count uint64
+    // seq orders entries by when they were added, to break ties in count
+    seq   uint64
+    index int
+}
+
+type lfuHeap []*lfuEntry
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+    if h[i].count != h[j].count {
+        return h[i].count < h[j].count
+    }
+    return h[i].seq < h[j].seq
+}
+
+func (h lfuHeap) Swap(i, j int) {
+    h[i], h[j] = h[j], h[i]
+    h[i].index = i
+    h[j].index = j
+}
+
+func (h *lfuHeap) Push(x interface{}) {
+    e := x.(*lfuEntry)
+    e.index = len(*h)
+    *h = append(*h, e)
+}
+
+func (h *lfuHeap) Pop() interface{} {
+    old := *h
+    n := len(old)
+    e := old[n-1]
+    old[n-1] = nil
+    *h = old[:n-1]
+    return e
+}
+
+type lfu struct {
+    h       lfuHeap
+    entries map[string]*lfuEntry
+    seq     uint64
+}
+
+func newLFU() *lfu {
+    return &lfu{
+        entries: make(map[string]*lfuEntry),
+    }
+}
+
+// add starts tracking a new key. Overwriting a key's value keeps its count.
+func (l *lfu) add(k string) {
+    if _, ok := l.entries[k]; ok {
+        return
+    }
+    l.seq++
+    e := &lfuEntry{key: k, seq: l.seq}
+    heap.Push(&l.h, e)
+    l.entries[k] = e
+}
+
+func (l *lfu) access(k string) {
+    if e, ok := l.entries[k]; ok {
+        e.count++
+        heap.Fix(&l.h, e.index)
+    }
+}
+
+func (l *lfu) remove(k string) {
+    if e, ok := l.entries[k]; ok {
+        heap.Remove(&l.h, e.index)
+        delete(l.entries, k)
+    }
+}
+
+func (l *lfu) victim(_ *cache, keep string) (string, bool) {
+    if len(l.h) == 0 {
+        return "", false
+    }
+    if l.h[0].key != keep {
+        return l.h[0].key, true
+    }
+    // The next least frequently read key is one of the root's children
+    switch {
+    case len(l.h) == 1:
+        return "", false
+    case len(l.h) == 2 || l.h.Less(1, 2):
+        return l.h[1].key, true
+    default:
+        return l.h[2].key, true
+    }
+}
+
+func (l *lfu) reset() {
+    l.h = nil
+    l.entries = make(map[string]*lfuEntry)
+}
+
+func (l *lfu) clone() evictor {
+    n := &lfu{
+        h:       make(lfuHeap, len(l.h)),
+        entries: make(map[string]*lfuEntry, len(l.entries)),
+        seq:     l.seq,
+    }
+    for i, e := range l.h {
+        ce := *e
+        n.h[i] = &ce
+        n.entries[e.key] = &ce
+    }
+    return n
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+    "bufio"
+    "bytes"
+    "fmt"
+    "io"
+    "os"
+)
+
+// LoadFileAuto loads a file written by SaveFile, SaveFileCompressed or
+// SaveFileEncrypted, telling them apart by their headers. key is only used
+// for encrypted files, and may be nil if there are none; nothing is loaded
+// from an encrypted file without the key it was saved with. A file in none of
+// these formats fails to load with an error saying so.
+func (c *cache) LoadFileAuto(name string, key []byte) error {
+    fp, err := os.Open(name)
+    if err != nil {
+        return err
+    }
+    err = c.loadAuto(fp, key)
+    if err != nil {
+        errFile := fp.Close()
+        if errFile != nil {
+            return errFile
+        }
+        return fmt.Errorf("loading %s: %w", name, err)
+    }
+    return fp.Close()
+}
+
+func (c *cache) loadAuto(r io.Reader, key []byte) error {
+    br := bufio.NewReader(r)
+    magic, _ := br.Peek(len(encryptedMagic))
+    switch {
+    case bytes.Equal(magic, encryptedMagic):
+        if key == nil {
+            return fmt.Errorf("the file is encrypted and no key was given")
+        }
+        data, err := io.ReadAll(br)
+        if err != nil {
+            return err
+        }
+        plain, err := decrypt(data, key)
+        if err != nil {
+            return err
+        }
+        return c.Load(bytes.NewReader(plain))
+    case bytes.HasPrefix(magic, gzipMagic):
+        return c.loadMaybeCompressed(br)
+    }
+    if err := c.Load(br); err != nil {
+        return fmt.Errorf("not a gob, gzip or encrypted cache file: %w", err)
+    }
+    return nil
+}
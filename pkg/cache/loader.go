@@ -0,0 +1,126 @@
+package cache
+
+import (
+    "context"
+    "fmt"
+    "time"
+)
+
+type loadCall struct {
+    done chan struct{}
+    val  interface{}
+    err  error
+    // abandoned reports that err came from the loading caller's context
+    abandoned bool
+}
+
+func (c *cache) GetOrLoad(k string, d time.Duration, loader func() (interface{}, error)) (interface{}, error) {
+    return c.getOrLoad(context.Background(), k, func() (interface{}, time.Duration, error) {
+        v, err := loader()
+        return v, d, err
+    })
+}
+
+// GetOrLoadContext is like GetOrLoad, but passes ctx to loader and returns
+// ctx.Err() once ctx is done. A call waiting for another call's load stops
+// waiting when its own ctx is done, without cancelling the load. If the load
+// it waits for fails because the other call's ctx is done, it loads k itself.
+func (c *cache) GetOrLoadContext(ctx context.Context, k string, d time.Duration, loader func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+    v, err := c.getOrLoad(ctx, k, func() (interface{}, time.Duration, error) {
+        v, err := loader(ctx)
+        return v, d, err
+    })
+    if err != nil && ctx.Err() != nil {
+        return nil, ctx.Err()
+    }
+    return v, err
+}
+
+// getOrLoad returns the live value under k, or runs loader to get it and
+// stores the result for the duration loader returns. Concurrent calls for the
+// same k share one call to loader; calls waiting for it give up when ctx is
+// done, and try again themselves if the call fails only because the caller
+// that made it gave up.
+func (c *cache) getOrLoad(ctx context.Context, k string, loader func() (interface{}, time.Duration, error)) (interface{}, error) {
+    for {
+        if v, found := c.Get(k); found {
+            return v, nil
+        }
+        c.loadMu.Lock()
+        l, ok := c.loads[k]
+        if !ok {
+            break
+        }
+        c.loadMu.Unlock()
+        select {
+        case <-l.done:
+            if !l.abandoned {
+                return l.val, l.err
+            }
+        case <-ctx.Done():
+            return nil, ctx.Err()
+        }
+    }
+    // c.loadMu is held. The value may have been stored by a load that
+    // finished after the Get above. Get would count a second miss, and
+    // could start a refresh-ahead, so look k up directly.
+    c.mu.RLock()
+    v, found := c.get(k)
+    c.mu.RUnlock()
+    if found {
+        c.loadMu.Unlock()
+        return v, nil
+    }
+    if c.loads == nil {
+        c.loads = make(map[string]*loadCall)
+    }
+    l := &loadCall{done: make(chan struct{})}
+    c.loads[k] = l
+    c.loadMu.Unlock()
+
+    defer func() {
+        // An error while ctx is done is taken to come from ctx, which the
+        // waiters don't share
+        l.abandoned = l.err != nil && ctx.Err() != nil
+        c.loadMu.Lock()
+        delete(c.loads, k)
+        c.loadMu.Unlock()
+        close(l.done)
+    }()
+    if err := c.acquireLoad(ctx); err != nil {
+        l.err = err
+        return nil, err
+    }
+    defer c.releaseLoad()
+    // Reported to waiters if loader panics before returning
+    l.err = fmt.Errorf("loader for %s panicked", k)
+    var d time.Duration
+    l.val, d, l.err = loader()
+    if l.err == nil {
+        // Loaded from the backing store, so not written back to it
+        if err := c.setLocally(k, l.val, d); err != nil {
+            c.handleError(err)
+        }
+    }
+    return l.val, l.err
+}
+
+// acquireLoad waits for one of the WithMaxConcurrentLoads slots to call a
+// loader in, returning ctx.Err() if ctx is done first.
+func (c *cache) acquireLoad(ctx context.Context) error {
+    if c.loadSem == nil {
+        return nil
+    }
+    select {
+    case c.loadSem <- struct{}{}:
+        return nil
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+func (c *cache) releaseLoad() {
+    if c.loadSem != nil {
+        <-c.loadSem
+    }
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+    "context"
+    "time"
+)
+
+// Loading is a read-through cache: Get fetches missing items with its loader
+// and stores them for the duration the loader returns.
+type Loading struct {
+    *Cache
+    loader   func(key string) (interface{}, time.Duration, error)
+    negative *Cache
+}
+
+type LoadingOption func(*Loading)
+
+// WithNegativeCaching makes Loading remember a loader error for d, returning
+// it from Get for that key instead of calling the loader again. The errors
+// are kept in a cache of their own, whose janitor Close stops.
+func WithNegativeCaching(d time.Duration) LoadingOption {
+    return func(l *Loading) {
+        if d > 0 {
+            l.negative = New(d, d)
+        }
+    }
+}
+
+func NewLoading(c *Cache, loader func(key string) (interface{}, time.Duration, error), opts ...LoadingOption) *Loading {
+    l := &Loading{
+        Cache:  c,
+        loader: loader,
+    }
+    for _, opt := range opts {
+        opt(l)
+    }
+    return l
+}
+
+func (l *Loading) Get(k string) (interface{}, error) {
+    if l.negative != nil {
+        if err, found := l.negative.Get(k); found {
+            return nil, err.(error)
+        }
+    }
+    v, err := l.getOrLoad(context.Background(), k, func() (interface{}, time.Duration, error) {
+        return l.loader(k)
+    })
+    if err != nil && l.negative != nil {
+        l.negative.SetDefault(k, err)
+    }
+    return v, err
+}
+
+// Close stops the janitors of the cache and of the one WithNegativeCaching
+// keeps the loader errors in.
+func (l *Loading) Close() {
+    l.Cache.Close()
+    if l.negative != nil {
+        l.negative.Close()
+    }
+}
+
+// Delete removes k, along with any loader error remembered for it.
+func (l *Loading) Delete(k string) {
+    l.Cache.Delete(k)
+    if l.negative != nil {
+        l.negative.Delete(k)
+    }
+}
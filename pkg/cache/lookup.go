@@ -0,0 +1,42 @@
+package cache
+
+// LookupState is what Lookup found under a key.
+type LookupState int
+
+const (
+    // LookupMiss means there is no item under the key, or only a cached
+    // miss stored with SetMiss.
+    LookupMiss LookupState = iota
+    // LookupHit means the item is live.
+    LookupHit
+    // LookupExpired means the item has expired but hasn't been deleted yet.
+    LookupExpired
+)
+
+func (s LookupState) String() string {
+    switch s {
+    case LookupMiss:
+        return "miss"
+    case LookupHit:
+        return "hit"
+    case LookupExpired:
+        return "expired"
+    }
+    return "unknown"
+}
+
+// Lookup returns the value under k and whether it is live, expired or
+// missing. Unlike Get it returns an expired item's value, for callers that
+// may serve it while they refresh it. Like Peek it doesn't count as a read.
+func (c *cache) Lookup(k string) (interface{}, LookupState) {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    item, found := c.items.Get(k)
+    if !found || item.Object == cachedMiss {
+        return nil, LookupMiss
+    }
+    if c.expired(item) {
+        return item.Object, LookupExpired
+    }
+    return item.Object, LookupHit
+}
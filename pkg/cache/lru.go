@@ -0,0 +1,64 @@
+package cache
+
+import "container/list"
+
+type lruPolicy struct{}
+
+func (lruPolicy) newEvictor() evictor {
+    return newLRU()
+}
+
+type lru struct {
+    ll    *list.List
+    elems map[string]*list.Element
+}
+
+func newLRU() *lru {
+    return &lru{
+        ll:    list.New(),
+        elems: make(map[string]*list.Element),
+    }
+}
+
+func (l *lru) add(k string) {
+    l.access(k)
+}
+
+func (l *lru) access(k string) {
+    if e, ok := l.elems[k]; ok {
+        l.ll.MoveToFront(e)
+        return
+    }
+    l.elems[k] = l.ll.PushFront(k)
+}
+
+func (l *lru) remove(k string) {
+    if e, ok := l.elems[k]; ok {
+        l.ll.Remove(e)
+        delete(l.elems, k)
+    }
+}
+
+func (l *lru) victim(_ *cache, keep string) (string, bool) {
+    e := l.ll.Back()
+    if e != nil && e.Value.(string) == keep {
+        e = e.Prev()
+    }
+    if e == nil {
+        return "", false
+    }
+    return e.Value.(string), true
+}
+
+func (l *lru) reset() {
+    l.ll.Init()
+    l.elems = make(map[string]*list.Element)
+}
+
+func (l *lru) clone() evictor {
+    n := newLRU()
+    for e := l.ll.Back(); e != nil; e = e.Prev() {
+        n.access(e.Value.(string))
+    }
+    return n
+}
@@ -0,0 +1,64 @@
+package cache
+
+import (
+    "reflect"
+    "unsafe"
+)
+
+// entryOverhead approximates what each item costs besides its key's bytes
+// and its value: the key's string header, the Item and the map's bookkeeping.
+const entryOverhead = int64(unsafe.Sizeof("")) + int64(unsafe.Sizeof(Item{})) + 16
+
+// MemoryUsage returns a rough estimate of the bytes the cache's items take
+// up. Values are measured with the WithMaxBytes sizer if there is one, and
+// otherwise by their type: strings and slices of fixed-size elements count
+// their contents, while other values only count their own size, not what
+// they point to. It's an approximation, not a measurement of the heap.
+func (c *cache) MemoryUsage() int64 {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    var n int64
+    c.items.Range(func(k string, v Item) bool {
+        n += entryOverhead + int64(len(k))
+        if c.sizer != nil {
+            n += c.sizes[k]
+        } else {
+            n += estimateSize(v.Object)
+        }
+        return true
+    })
+    return n
+}
+
+func estimateSize(x interface{}) int64 {
+    switch v := x.(type) {
+    case nil:
+        return 0
+    case string:
+        return int64(unsafe.Sizeof(v)) + int64(len(v))
+    case []byte:
+        return int64(unsafe.Sizeof(v)) + int64(cap(v))
+    }
+    rv := reflect.ValueOf(x)
+    size := int64(rv.Type().Size())
+    switch rv.Kind() {
+    case reflect.String:
+        size += int64(rv.Len())
+    case reflect.Slice:
+        if elem := rv.Type().Elem(); isFlat(elem.Kind()) {
+            size += int64(rv.Cap()) * int64(elem.Size())
+        }
+    }
+    return size
+}
+
+// isFlat reports whether values of kind k hold no pointers.
+func isFlat(k reflect.Kind) bool {
+    switch k {
+    case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+        reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+        reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+        return true
+    }
+    return false
+}
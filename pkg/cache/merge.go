@@ -0,0 +1,36 @@
+package cache
+
+import "unsafe"
+
+// Merge copies the unexpired items in other into c, keeping their
+// expirations. Items already in c are kept unless overwrite is set.
+func (c *Cache) Merge(other *Cache, overwrite bool) {
+    if c.cache == other.cache {
+        return
+    }
+    // Lock in address order so that concurrent a.Merge(b) and b.Merge(a)
+    // can't deadlock
+    if uintptr(unsafe.Pointer(c.cache)) < uintptr(unsafe.Pointer(other.cache)) {
+        c.mu.Lock()
+        other.mu.RLock()
+    } else {
+        other.mu.RLock()
+        c.mu.Lock()
+    }
+    var evicted []keyAndValue
+    other.items.Range(func(k string, v Item) bool {
+        if other.expired(v) {
+            return true
+        }
+        if !overwrite {
+            if _, found := c.get(k); found {
+                return true
+            }
+        }
+        evicted = append(evicted, c.insert(k, v)...)
+        return true
+    })
+    other.mu.RUnlock()
+    c.mu.Unlock()
+    c.notifyEvicted(evicted)
+}
@@ -0,0 +1,79 @@
+package cache
+
+import "time"
+
+// missEntry is the value SetMiss stores. It's a bool rather than an empty
+// struct so that gob can save it.
+type missEntry bool
+
+const cachedMiss missEntry = true
+
+// SetMiss records that k is known not to exist, for d. Get treats the entry as
+// a miss; GetEntry and Lookup report it as one. Every other method treats k as
+// absent, and the eviction callbacks aren't called for the entry. It expires
+// like any other item.
+func (c *cache) SetMiss(k string, d time.Duration) {
+    if err := c.setLocally(k, cachedMiss, d); err != nil {
+        c.handleError(err)
+    }
+}
+
+// GetEntry is like Get, but also finds entries stored with SetMiss, reporting
+// them with isMiss set.
+func (c *cache) GetEntry(k string) (value interface{}, isMiss bool, found bool) {
+    item, found := c.getItem(k)
+    if !found {
+        c.stats.miss()
+        return nil, false, false
+    }
+    c.stats.hit()
+    if item.Object == cachedMiss {
+        return nil, true, true
+    }
+    return item.Object, false, true
+}
+
+// getItem returns the live item under k, recording the read where the cache
+// tracks them.
+func (c *cache) getItem(k string) (Item, bool) {
+    if c.recordsReads() {
+        return c.touchGet(k)
+    }
+    c.mu.RLock()
+    item, found := c.items.Get(k)
+    c.mu.RUnlock()
+    if !found || c.readExpired(item) {
+        return Item{}, false
+    }
+    if item.Idle > 0 {
+        return c.touchGet(k)
+    }
+    if c.evictor != nil {
+        c.bufferRead(k)
+    }
+    return item, true
+}
+
+// live reports whether item holds a value that reads return at now: it
+// hasn't expired and isn't a cached miss.
+func live(item Item, now int64) bool {
+    return (item.Expiration <= 0 || now <= item.Expiration) && item.Object != cachedMiss
+}
+
+// withoutMisses returns evicted without the cached misses, which eviction
+// callbacks aren't told about as they hold no value.
+func withoutMisses(evicted []keyAndValue) []keyAndValue {
+    for i, v := range evicted {
+        if v.value != cachedMiss {
+            continue
+        }
+        kept := append([]keyAndValue{}, evicted[:i]...)
+        for _, v := range evicted[i+1:] {
+            if v.value != cachedMiss {
+                kept = append(kept, v)
+            }
+        }
+        return kept
+    }
+    return evicted
+}
@@ -5,28 +5,81 @@ import (
     "time"
 )
 
-func newCache(de time.Duration, m map[string]Item) *cache {
-    if de == 0 {
-        de = -1
-    }
+func newCache(m map[string]Item, opts ...Option) *cache {
     c := &cache{
-        defaultExpiration: de,
-        items:             m,
+        expirations: newExpirations(),
+    }
+    for _, opt := range opts {
+        opt(c)
+    }
+    if c.items == nil {
+        c.items = mapStore(m)
+    } else {
+        for k, v := range m {
+            c.items.Set(k, v)
+        }
+    }
+    if c.defaultExpiration.Load() == 0 {
+        c.defaultExpiration.Store(-1)
+    }
+    if c.clock == nil {
+        c.clock = realClock{}
+    }
+    if c.maxItems > 0 || (c.sizer != nil && c.maxBytes > 0) {
+        if c.policy == nil {
+            c.policy = LRU
+        }
+        c.evictor = c.policy.newEvictor()
+        if _, ok := c.policy.(SampledLRU); ok {
+            c.stampReads = true
+        }
+    }
+    if c.sizer != nil {
+        c.sizes = make(map[string]int64, c.items.Len())
+    }
+    c.count.Store(int64(c.items.Len()))
+    // A store passed WithStore may already hold items
+    c.items.Range(func(k string, v Item) bool {
+        c.expirations.update(k, v.Expiration)
+        if c.evictor != nil {
+            c.evictor.add(k)
+        }
+        if c.sizer != nil {
+            size := c.sizeOf(k, v.Object)
+            c.sizes[k] = size
+            c.bytes += size
+        }
+        return true
+    })
+    if c.precise {
+        c.expirations.onChange = c.armTimer
+        c.armTimer()
     }
     return c
 }
 
-func newCacheWithJanitor(de time.Duration, ci time.Duration, m map[string]Item) *Cache {
-    c := newCache(de, m)
+func newCacheWithJanitor(m map[string]Item, opts ...Option) *Cache {
+    return startJanitor(newCache(m, opts...))
+}
+
+// startJanitor wraps c and starts its janitor if it has a cleanup interval.
+func startJanitor(c *cache) *Cache {
     C := &Cache{c}
-    if ci > 0 {
-        runJanitor(c, ci)
+    if c.cleanupInterval > 0 {
+        runJanitor(c, c.cleanupInterval)
         runtime.SetFinalizer(C, stopJanitor)
     }
     return C
 }
 
-func New(defaultExpiration, cleanupInterval time.Duration) *Cache {
+func New(defaultExpiration, cleanupInterval time.Duration, opts ...Option) *Cache {
+    return NewWithOptions(append([]Option{
+        WithDefaultExpiration(defaultExpiration),
+        WithCleanupInterval(cleanupInterval),
+    }, opts...)...)
+}
+
+func NewWithOptions(opts ...Option) *Cache {
     items := make(map[string]Item)
-    return newCacheWithJanitor(defaultExpiration, cleanupInterval, items)
+    return newCacheWithJanitor(items, opts...)
 }
@@ -0,0 +1,276 @@
+package cache
+
+import (
+    "fmt"
+    "strings"
+    "time"
+    "unicode"
+)
+
+type Option func(*cache)
+
+func WithDefaultExpiration(d time.Duration) Option {
+    return func(c *cache) {
+        c.defaultExpiration.Store(int64(d))
+    }
+}
+
+func WithCleanupInterval(d time.Duration) Option {
+    return func(c *cache) {
+        c.cleanupInterval = d
+    }
+}
+
+func WithClock(clock Clock) Option {
+    return func(c *cache) {
+        c.clock = clock
+    }
+}
+
+// WithExpirationJitter moves each expiration, when it's set, by a random
+// amount up to fraction of its duration either way, so items stored together
+// don't all expire together. Items that never expire are unaffected.
+func WithExpirationJitter(fraction float64) Option {
+    return func(c *cache) {
+        c.jitter = fraction
+    }
+}
+
+// WithStore makes the cache keep its items in store instead of a map. Any
+// items already in store are part of the cache. A store must not be shared
+// between caches, so NewSharded panics if given WithStore.
+func WithStore(store Store) Option {
+    return func(c *cache) {
+        c.items = store
+    }
+}
+
+// WithWriteThrough mirrors writes to a backing store by calling onSet or
+// onDelete after the cache itself is updated, so reads never wait for the
+// store. Either may be nil. onSet is called for each value stored by Set,
+// SetDefault, SetChecked, SetThrough, SetWithIdle, SetWithDeadline, Add,
+// AddOrGet, Replace, SetIfAbsent, GetOrSet, GetSet, CompareAndSwap, Update,
+// the SetMany methods, the Increment and Decrement methods, IncrementMany,
+// and for the destinations of RenameKey and CopyKey. onDelete is called by
+// Delete, DeleteThrough, DeleteMany, GetAndDelete, DeleteByPrefix, RemoveIf
+// and Update when they delete, and for the source of RenameKey. Errors from
+// them are returned by SetThrough and DeleteThrough, and passed to the
+// WithErrorHandler handler by the rest.
+//
+// onSet gets the default expiration in place of DefaultExpiration, and the
+// time left for items that keep their expiration or are given a deadline.
+// It isn't called for cached misses, for values stored by GetOrLoad,
+// Loading and WithRefreshAhead, which came from the store, or by Load and
+// Restore. Items that expire or are evicted, and those removed by Flush,
+// Reset and Drain, aren't deleted from the store, and changes to an item's
+// expiration alone, such as by Touch, aren't mirrored.
+func WithWriteThrough(onSet func(k string, v interface{}, d time.Duration) error, onDelete func(k string) error) Option {
+    return func(c *cache) {
+        c.writeSet = onSet
+        c.writeDelete = onDelete
+    }
+}
+
+// WithMaxKeyLength makes every method that stores an item under a new key
+// refuse keys longer than n bytes. Methods that return an error, such as
+// SetChecked, SetManyChecked, Add and Replace, return it; the others store
+// nothing under the key and pass the error to the WithErrorHandler handler.
+func WithMaxKeyLength(n int) Option {
+    return func(c *cache) {
+        c.maxKeyLength = n
+    }
+}
+
+// WithKeyValidator makes every method that stores an item under a new key
+// refuse keys for which validate returns an error, reporting it as for
+// WithMaxKeyLength. validate runs on every one of those writes, so it should
+// be cheap; reads and deletes don't call it.
+func WithKeyValidator(validate func(k string) error) Option {
+    return func(c *cache) {
+        c.keyValidator = validate
+    }
+}
+
+// BasicKeyValidator returns a validator for WithKeyValidator that rejects
+// empty keys, keys containing white space and keys longer than maxLength
+// bytes.
+func BasicKeyValidator(maxLength int) func(k string) error {
+    return func(k string) error {
+        if k == "" {
+            return fmt.Errorf("empty key")
+        }
+        if len(k) > maxLength {
+            return fmt.Errorf("key of %d bytes is longer than the limit of %d", len(k), maxLength)
+        }
+        if strings.IndexFunc(k, unicode.IsSpace) >= 0 {
+            return fmt.Errorf("key %q contains white space", k)
+        }
+        return nil
+    }
+}
+
+// WithRefreshAhead makes Get start refreshing an item in the background when
+// it has less than window left before it expires, storing the value and
+// duration refresh returns. Get still returns the current value, and only one
+// refresh runs for a key at a time. If refresh fails the item is left to
+// expire, and the error is passed to the WithErrorHandler handler.
+func WithRefreshAhead(window time.Duration, refresh func(k string) (interface{}, time.Duration, error)) Option {
+    return func(c *cache) {
+        c.refreshWindow = window
+        c.refresh = refresh
+    }
+}
+
+// WithEvictionLog keeps the key, reason and time of the last size items to
+// leave the cache, for RecentEvictions. Values aren't kept.
+func WithEvictionLog(size int) Option {
+    return func(c *cache) {
+        if size > 0 {
+            c.evictionLog = &evictionLog{records: make([]EvictionRecord, size)}
+        }
+    }
+}
+
+// WithLazyExpiration(false) stops the methods that read or look up a single
+// key, such as Get, Peek, GetWithExpiration, GetMany, TTL, Add and Update,
+// comparing expirations with the current time: items are found until
+// DeleteExpired or the janitor deletes them. That saves reading the clock on
+// each call, at the cost of sometimes returning values that have expired.
+// GetStale and Lookup still report whether an item has expired, and the
+// methods over many items, such as Items, Keys, ForEach and LiveCount, still
+// skip expired ones. true, the default, restores the check.
+func WithLazyExpiration(lazy bool) Option {
+    return func(c *cache) {
+        c.janitorExpiresOnly = !lazy
+    }
+}
+
+// WithPreciseExpiration deletes items as soon as they expire, calling the
+// eviction callbacks then rather than at the janitor's next run. Instead of a
+// timer per item, it keeps one timer for the cache, set for the soonest
+// expiration, so it costs no memory beyond the per-item entry the cache
+// already keeps to order expirations. The timer runs in real time, so it
+// won't follow a Clock set WithClock.
+func WithPreciseExpiration() Option {
+    return func(c *cache) {
+        c.precise = true
+    }
+}
+
+// WithMaxPendingExpired makes Set call DeleteExpired once the cache holds n
+// expired items the janitor hasn't deleted yet, bounding how many can pile up
+// between its runs. While nothing has expired, checking costs each Set a look
+// at the soonest expiration. Once something has, only every n/64-th Set
+// counts the expired items, so up to n/64 more Sets can pass after the nth
+// expires before one notices, and the Set that finds n of them also waits for
+// DeleteExpired.
+func WithMaxPendingExpired(n int) Option {
+    return func(c *cache) {
+        c.maxPendingExpired = n
+    }
+}
+
+// WithIncrementalCleanup limits each janitor run to holding the lock for
+// about maxDuration, so a run with a large number of expired items to delete
+// doesn't stall Get and Set meanwhile. Expired items it doesn't get to are
+// left for the next run, as if it hadn't run yet. DeleteExpired and
+// PurgeExpired are not limited.
+func WithIncrementalCleanup(maxDuration time.Duration) Option {
+    return func(c *cache) {
+        c.cleanupBudget = maxDuration
+    }
+}
+
+// WithAdaptiveTTL makes the expirations of items stored with Set and the
+// like follow how often they are read: each read moves an item's expiration
+// to the time it was stored plus what ttl returns for its AccessCount and the
+// duration it was stored for, so ttl can keep hot items longer and let cold
+// ones go. A ttl of zero or less leaves the expiration as it is. Items that
+// never expire, those stored with SetWithIdle or SetWithDeadline, and those
+// whose expiration has since been set by Touch, SetExpiration and the like
+// don't adapt. It turns on WithAccessTracking to count the reads.
+func WithAdaptiveTTL(ttl func(accessCount int64, baseTTL time.Duration) time.Duration) Option {
+    return func(c *cache) {
+        c.adaptiveTTL = ttl
+        c.trackAccess = true
+    }
+}
+
+// WithMaxConcurrentLoads limits how many loaders, across all keys, GetOrLoad,
+// GetOrLoadContext, Loading and WithRefreshAhead run at once to n. Calls
+// beyond the limit wait for a running loader to finish, or until their
+// context is done. That adds latency whenever loads pile up, which is the
+// point: the backend sees no more than n requests at a time.
+func WithMaxConcurrentLoads(n int) Option {
+    return func(c *cache) {
+        if n > 0 {
+            c.loadSem = make(chan struct{}, n)
+        }
+    }
+}
+
+// WithErrorHandler sets a function to receive errors from work the cache does
+// in the background, such as AutoSave, and panics recovered from eviction
+// callbacks.
+func WithErrorHandler(f func(error)) Option {
+    return func(c *cache) {
+        c.onError = f
+    }
+}
+
+// WithAccessTracking keeps each item's LastAccess and AccessCount up to date.
+// Recording reads means Get takes the write lock.
+func WithAccessTracking() Option {
+    return func(c *cache) {
+        c.trackAccess = true
+    }
+}
+
+// Sizer returns the number of bytes a value counts for against WithMaxBytes.
+type Sizer func(interface{}) int64
+
+// WithMaxBytes limits the total size of the cache's values, as measured by
+// sizer, evicting items by the eviction policy to stay within it. It can be
+// combined with WithMaxItems. sizer is only given values stored by the
+// caller; if it panics, the value counts as 0 bytes and the panic is passed
+// to the WithErrorHandler handler.
+func WithMaxBytes(n int64, sizer Sizer) Option {
+    return func(c *cache) {
+        c.maxBytes = n
+        c.sizer = sizer
+    }
+}
+
+// WithEvictionPolicy sets how a cache created WithMaxItems chooses which item
+// to evict. It has no effect without an item limit.
+func WithEvictionPolicy(p EvictionPolicy) Option {
+    return func(c *cache) {
+        c.policy = p
+    }
+}
+
+func WithStats() Option {
+    return func(c *cache) {
+        if c.stats == nil {
+            c.stats = &stats{}
+        }
+    }
+}
+
+// WithLatencyStats records how long each Get and Set takes in the
+// GetLatency and SetLatency histograms of Stats, and implies WithStats.
+// Timing every call adds a little to each.
+func WithLatencyStats() Option {
+    return func(c *cache) {
+        if c.stats == nil {
+            c.stats = &stats{}
+        }
+        c.stats.latency = &latencyStats{}
+    }
+}
+
+func WithMaxItems(n int) Option {
+    return func(c *cache) {
+        c.maxItems = n
+    }
+}
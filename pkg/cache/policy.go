@@ -0,0 +1,99 @@
+package cache
+
+import "fmt"
+
+// EvictionPolicy decides which item a cache created WithMaxItems evicts when
+// it is full.
+type EvictionPolicy interface {
+    newEvictor() evictor
+}
+
+var (
+    // LRU evicts the least recently used item. It is the default.
+    LRU EvictionPolicy = lruPolicy{}
+    // LFU evicts the least frequently read item, and the one that has been
+    // in the cache longest among equally read items.
+    LFU EvictionPolicy = lfuPolicy{}
+)
+
+// evictor tracks the keys of a cache with an item limit to choose which to
+// evict. Its methods are called with c.mu held for writing. victim is passed
+// the cache, for policies that choose from its items rather than their own
+// record of them, and the key being made room for, which it must not choose.
+type evictor interface {
+    add(k string)
+    access(k string)
+    remove(k string)
+    victim(c *cache, keep string) (string, bool)
+    reset()
+    clone() evictor
+}
+
+// makeRoom evicts items chosen by the eviction policy until x can be stored
+// under k without going over the cache's item or byte limits, and accounts
+// for x's size. found reports whether k already holds an item. It must be
+// called with c.mu held, before x is stored.
+func (c *cache) makeRoom(k string, found bool, x interface{}) []keyAndValue {
+    var evicted []keyAndValue
+    if c.evictor != nil {
+        // Choose victims knowing about every read so far
+        c.applyReads()
+    }
+    if c.maxItems > 0 && !found {
+        for c.items.Len() >= c.maxItems {
+            kv, ok := c.evictOne(k)
+            if !ok {
+                break
+            }
+            evicted = append(evicted, kv)
+        }
+    }
+    if c.sizer == nil {
+        return evicted
+    }
+    size := c.sizeOf(k, x)
+    if found {
+        // k stays in the evictor, keeping its place or count; evictOne
+        // passes it to victim so it isn't chosen to make room for itself
+        c.bytes -= c.sizes[k]
+    }
+    if c.maxBytes > 0 {
+        for c.bytes+size > c.maxBytes {
+            kv, ok := c.evictOne(k)
+            if !ok {
+                break
+            }
+            evicted = append(evicted, kv)
+        }
+    }
+    c.bytes += size
+    c.sizes[k] = size
+    return evicted
+}
+
+// sizeOf returns the size of x, stored under k, by the WithMaxBytes sizer.
+// Cached misses take no space, and aren't passed to the sizer, which only
+// expects the caller's values. If the sizer panics, x counts as empty and the
+// panic is reported from another goroutine, as c.mu is held.
+func (c *cache) sizeOf(k string, x interface{}) (size int64) {
+    if x == cachedMiss {
+        return 0
+    }
+    defer func() {
+        if r := recover(); r != nil {
+            size = 0
+            go c.handleError(fmt.Errorf("sizer for %s panicked: %v", k, r))
+        }
+    }()
+    return c.sizer(x)
+}
+
+func (c *cache) evictOne(keep string) (keyAndValue, bool) {
+    k, ok := c.evictor.victim(c, keep)
+    if !ok {
+        return keyAndValue{}, false
+    }
+    v, _ := c.delete(k)
+    c.stats.evicted()
+    return keyAndValue{k, v, EvictOverflowed}, true
+}
@@ -0,0 +1,46 @@
+package cache
+
+import "time"
+
+// armTimer sets the timer of a cache created WithPreciseExpiration for the
+// soonest expiration. It must be called with c.mu held.
+func (c *cache) armTimer() {
+    _, e, ok := c.expirations.next()
+    if !ok {
+        if c.timer != nil {
+            c.timer.Stop()
+        }
+        c.timerAt = 0
+        return
+    }
+    if e == c.timerAt {
+        return
+    }
+    c.timerAt = e
+    // Items expire once the time is past their expiration
+    d := time.Duration(e-c.now()) + 1
+    if c.timer == nil {
+        c.timer = time.AfterFunc(d, c.expireOnTimer)
+    } else {
+        c.timer.Reset(d)
+    }
+}
+
+func (c *cache) expireOnTimer() {
+    c.DeleteExpired()
+    // Set the timer again even if DeleteExpired found nothing to delete and
+    // so didn't change the expirations
+    c.mu.Lock()
+    c.timerAt = 0
+    c.armTimer()
+    c.mu.Unlock()
+}
+
+func (c *cache) stopTimer() {
+    c.mu.Lock()
+    if c.timer != nil {
+        c.timer.Stop()
+    }
+    c.expirations.onChange = nil
+    c.mu.Unlock()
+}
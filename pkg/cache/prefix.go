@@ -0,0 +1,83 @@
+package cache
+
+import "strings"
+
+func (c *cache) DeleteByPrefix(prefix string) int {
+    var evicted []keyAndValue
+    c.mu.Lock()
+    notify := c.notifiesEvictions()
+    var keys []string
+    c.items.Range(func(k string, _ Item) bool {
+        if strings.HasPrefix(k, prefix) {
+            keys = append(keys, k)
+        }
+        return true
+    })
+    n := 0
+    for _, k := range keys {
+        v, _ := c.delete(k)
+        c.stats.evicted()
+        if v != cachedMiss {
+            n++
+        }
+        if notify {
+            evicted = append(evicted, keyAndValue{k, v, EvictDeleted})
+        }
+    }
+    c.mu.Unlock()
+    c.notifyEvicted(evicted)
+    for _, k := range keys {
+        c.mirrorDelete(k)
+    }
+    return n
+}
+
+// RemoveIf deletes every item, expired or not, for which pred returns true,
+// under one lock, and returns how many it deleted. Cached misses aren't
+// passed to pred and are kept. pred runs with the lock held, so it must not
+// use the cache; the eviction callbacks are called once it's released.
+func (c *cache) RemoveIf(pred func(k string, item Item) bool) int {
+    var evicted []keyAndValue
+    c.mu.Lock()
+    notify := c.notifiesEvictions()
+    var keys []string
+    c.items.Range(func(k string, item Item) bool {
+        if item.Object != cachedMiss && pred(k, item) {
+            keys = append(keys, k)
+        }
+        return true
+    })
+    for _, k := range keys {
+        v, _ := c.delete(k)
+        c.stats.evicted()
+        if notify {
+            evicted = append(evicted, keyAndValue{k, v, EvictDeleted})
+        }
+    }
+    c.mu.Unlock()
+    c.notifyEvicted(evicted)
+    for _, k := range keys {
+        c.mirrorDelete(k)
+    }
+    return len(keys)
+}
+
+// CountByPrefix returns the number of unexpired items whose keys start with
+// prefix. It looks at every key in the cache, so it takes time proportional
+// to the item count however few keys match.
+func (c *cache) CountByPrefix(prefix string) int {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    now := c.now()
+    n := 0
+    c.items.Range(func(k string, v Item) bool {
+        if !live(v, now) {
+            return true
+        }
+        if strings.HasPrefix(k, prefix) {
+            n++
+        }
+        return true
+    })
+    return n
+}
@@ -0,0 +1,49 @@
+package prometheus
+
+import (
+    "github.com/d3code/xcache/pkg/cache"
+    prom "github.com/prometheus/client_golang/prometheus"
+)
+
+type collector struct {
+    c           *cache.Cache
+    items       *prom.Desc
+    hits        *prom.Desc
+    misses      *prom.Desc
+    evictions   *prom.Desc
+    expirations *prom.Desc
+}
+
+// NewCollector returns a Collector for c's item count and stats. The stats
+// are only counted if c was created with cache.WithStats; otherwise they
+// stay at zero.
+func NewCollector(c *cache.Cache, namespace string) prom.Collector {
+    desc := func(name, help string) *prom.Desc {
+        return prom.NewDesc(prom.BuildFQName(namespace, "cache", name), help, nil, nil)
+    }
+    return &collector{
+        c:           c,
+        items:       desc("items", "Number of items in the cache, including expired items not yet deleted."),
+        hits:        desc("hits_total", "Number of lookups that found a live item."),
+        misses:      desc("misses_total", "Number of lookups that found no live item."),
+        evictions:   desc("evictions_total", "Number of items removed other than by expiring."),
+        expirations: desc("expirations_total", "Number of expired items deleted."),
+    }
+}
+
+func (col *collector) Describe(ch chan<- *prom.Desc) {
+    ch <- col.items
+    ch <- col.hits
+    ch <- col.misses
+    ch <- col.evictions
+    ch <- col.expirations
+}
+
+func (col *collector) Collect(ch chan<- prom.Metric) {
+    s := col.c.Stats()
+    ch <- prom.MustNewConstMetric(col.items, prom.GaugeValue, float64(col.c.ItemCount()))
+    ch <- prom.MustNewConstMetric(col.hits, prom.CounterValue, float64(s.Hits))
+    ch <- prom.MustNewConstMetric(col.misses, prom.CounterValue, float64(s.Misses))
+    ch <- prom.MustNewConstMetric(col.evictions, prom.CounterValue, float64(s.Evictions))
+    ch <- prom.MustNewConstMetric(col.expirations, prom.CounterValue, float64(s.Expirations))
+}
@@ -0,0 +1,48 @@
+package cache
+
+// readBufferSize is how many reads bufferRead collects before passing them to
+// the eviction policy.
+const readBufferSize = 64
+
+// bufferRead records reads of keys for the eviction policy. Rather than take
+// the write lock for every read, as the policy needs, it collects them and
+// takes it once per readBufferSize reads, or before items are next evicted.
+// So the policy sees reads in batches, and not always in their true order
+// relative to the stores between them.
+func (c *cache) bufferRead(keys ...string) {
+    c.readMu.Lock()
+    c.reads = append(c.reads, keys...)
+    full := len(c.reads) >= readBufferSize
+    c.readMu.Unlock()
+    if full {
+        c.mu.Lock()
+        c.applyReads()
+        c.mu.Unlock()
+    }
+}
+
+// applyReads passes the buffered reads to the eviction policy, and sets
+// their items' LastAccess for SampledLRU. It must be called with c.mu held
+// for writing.
+func (c *cache) applyReads() {
+    c.readMu.Lock()
+    reads := c.reads
+    c.reads = nil
+    c.readMu.Unlock()
+    var now int64
+    if c.stampReads && len(reads) > 0 {
+        now = c.now()
+    }
+    for _, k := range reads {
+        // The item may have gone since it was read
+        item, found := c.items.Get(k)
+        if !found {
+            continue
+        }
+        c.evictor.access(k)
+        if now != 0 {
+            item.LastAccess = now
+            c.items.Set(k, item)
+        }
+    }
+}
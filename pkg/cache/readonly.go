@@ -0,0 +1,51 @@
+package cache
+
+import "time"
+
+// ReadOnly is the reading half of a Cache, for code that should see its
+// items without changing them.
+type ReadOnly interface {
+    Get(k string) (interface{}, bool)
+    GetWithExpiration(k string) (interface{}, time.Time, bool)
+    TTL(k string) (time.Duration, bool)
+    Items() map[string]Item
+    Keys() []string
+    ItemCount() int
+}
+
+// readOnly wraps the cache rather than being it, so a ReadOnly can't be
+// asserted back to a *Cache.
+type readOnly struct {
+    c *cache
+}
+
+// ReadOnly returns a view of the cache that only has ReadOnly's methods. The
+// view holds no copy: it sees every change made through the cache itself.
+// Reads through it count towards Stats and recency as other reads do.
+func (c *Cache) ReadOnly() ReadOnly {
+    return readOnly{c.cache}
+}
+
+func (r readOnly) Get(k string) (interface{}, bool) {
+    return r.c.Get(k)
+}
+
+func (r readOnly) GetWithExpiration(k string) (interface{}, time.Time, bool) {
+    return r.c.GetWithExpiration(k)
+}
+
+func (r readOnly) TTL(k string) (time.Duration, bool) {
+    return r.c.TTL(k)
+}
+
+func (r readOnly) Items() map[string]Item {
+    return r.c.Items()
+}
+
+func (r readOnly) Keys() []string {
+    return r.c.Keys()
+}
+
+func (r readOnly) ItemCount() int {
+    return r.c.ItemCount()
+}
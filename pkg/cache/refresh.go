@@ -0,0 +1,42 @@
+package cache
+
+import (
+    "context"
+    "fmt"
+)
+
+// refreshAhead starts refreshing k if item, just read from it, is close
+// enough to expiring.
+func (c *cache) refreshAhead(k string, item Item) {
+    if c.refresh == nil || item.Expiration <= 0 || item.Expiration-c.now() >= int64(c.refreshWindow) {
+        return
+    }
+    c.refreshMu.Lock()
+    if _, ok := c.refreshing[k]; ok {
+        c.refreshMu.Unlock()
+        return
+    }
+    if c.refreshing == nil {
+        c.refreshing = make(map[string]struct{})
+    }
+    c.refreshing[k] = struct{}{}
+    c.refreshMu.Unlock()
+
+    go func() {
+        defer func() {
+            c.refreshMu.Lock()
+            delete(c.refreshing, k)
+            c.refreshMu.Unlock()
+        }()
+        c.acquireLoad(context.Background())
+        defer c.releaseLoad()
+        v, d, err := c.refresh(k)
+        if err != nil {
+            c.handleError(fmt.Errorf("refreshing %s: %w", k, err))
+            return
+        }
+        if err := c.setLocally(k, v, d); err != nil {
+            c.handleError(err)
+        }
+    }()
+}
@@ -0,0 +1,71 @@
+package cache
+
+// defaultSamples is how many items SampledLRU compares if Samples isn't set.
+const defaultSamples = 5
+
+// SampledLRU approximates LRU without keeping items in order of use: to
+// evict, it takes Samples items, or 5 if Samples is zero or less, as
+// RandomKeys does and evicts the least recently read of them, preferring any
+// that have expired. Items are compared by LastAccess, or by Created if they
+// haven't been read. The cache keeps LastAccess up to date for this without
+// WithAccessTracking, by setting it when buffered reads are applied, so reads
+// don't take the write lock and LastAccess can lag the true read by up to a
+// buffer's worth of reads.
+type SampledLRU struct {
+    Samples int
+}
+
+func (p SampledLRU) newEvictor() evictor {
+    n := p.Samples
+    if n <= 0 {
+        n = defaultSamples
+    }
+    return &sampled{samples: n}
+}
+
+// sampled keeps no record of the keys, choosing victims from the cache's
+// items instead.
+type sampled struct {
+    samples int
+}
+
+func (s *sampled) add(string) {}
+
+func (s *sampled) access(string) {}
+
+func (s *sampled) remove(string) {}
+
+func (s *sampled) victim(c *cache, keep string) (string, bool) {
+    now := c.now()
+    var victim string
+    var oldest int64
+    var expired, found bool
+    n := 0
+    c.items.Range(func(k string, v Item) bool {
+        if k == keep {
+            return true
+        }
+        n++
+        if v.Expiration > 0 && now > v.Expiration {
+            if !expired {
+                victim, expired, found = k, true, true
+            }
+            return n < s.samples
+        }
+        last := v.LastAccess
+        if last == 0 {
+            last = v.Created
+        }
+        if !expired && (!found || last < oldest) {
+            victim, oldest, found = k, last, true
+        }
+        return n < s.samples
+    })
+    return victim, found
+}
+
+func (s *sampled) reset() {}
+
+func (s *sampled) clone() evictor {
+    return &sampled{samples: s.samples}
+}
@@ -0,0 +1,334 @@
+package cache
+
+import (
+    "encoding/gob"
+    "hash/maphash"
+    "io"
+    "os"
+    "runtime"
+    "sync"
+    "time"
+)
+
+type Sharded struct {
+    *sharded
+}
+
+type sharded struct {
+    seed      maphash.Seed
+    shards    []*cache
+    janitor   *janitor
+    janitorMu sync.Mutex
+}
+
+func (sc *sharded) shard(k string) *cache {
+    return sc.shards[maphash.String(sc.seed, k)%uint64(len(sc.shards))]
+}
+
+func (sc *sharded) Set(k string, x interface{}, d time.Duration) {
+    sc.shard(k).Set(k, x, d)
+}
+
+func (sc *sharded) SetDefault(k string, x interface{}) {
+    sc.shard(k).SetDefault(k, x)
+}
+
+func (sc *sharded) DefaultExpiration() time.Duration {
+    return sc.shards[0].DefaultExpiration()
+}
+
+func (sc *sharded) SetDefaultExpiration(d time.Duration) {
+    for _, c := range sc.shards {
+        c.SetDefaultExpiration(d)
+    }
+}
+
+func (sc *sharded) Add(k string, x interface{}, d time.Duration) error {
+    return sc.shard(k).Add(k, x, d)
+}
+
+func (sc *sharded) Replace(k string, x interface{}, d time.Duration) error {
+    return sc.shard(k).Replace(k, x, d)
+}
+
+func (sc *sharded) IncrementInt(k string, n int) (int, error) {
+    return sc.shard(k).IncrementInt(k, n)
+}
+
+func (sc *sharded) IncrementFloat64(k string, n float64) (float64, error) {
+    return sc.shard(k).IncrementFloat64(k, n)
+}
+
+func (sc *sharded) DecrementInt(k string, n int) (int, error) {
+    return sc.shard(k).DecrementInt(k, n)
+}
+
+func (sc *sharded) DecrementFloat64(k string, n float64) (float64, error) {
+    return sc.shard(k).DecrementFloat64(k, n)
+}
+
+func (sc *sharded) Get(k string) (interface{}, bool) {
+    return sc.shard(k).Get(k)
+}
+
+func (sc *sharded) GetWithExpiration(k string) (interface{}, time.Time, bool) {
+    return sc.shard(k).GetWithExpiration(k)
+}
+
+func (sc *sharded) TTL(k string) (time.Duration, bool) {
+    return sc.shard(k).TTL(k)
+}
+
+func (sc *sharded) Delete(k string) {
+    sc.shard(k).Delete(k)
+}
+
+func (sc *sharded) GetAndDelete(k string) (interface{}, bool) {
+    return sc.shard(k).GetAndDelete(k)
+}
+
+// DeleteExpired cleans one shard at a time, so only a single shard is locked
+// at any moment.
+func (sc *sharded) DeleteExpired() {
+    for _, c := range sc.shards {
+        c.DeleteExpired()
+    }
+}
+
+func (sc *sharded) PurgeExpired() int {
+    n := 0
+    for _, c := range sc.shards {
+        n += c.PurgeExpired()
+    }
+    return n
+}
+
+func (sc *sharded) cleanup() {
+    for _, c := range sc.shards {
+        c.cleanup()
+    }
+}
+
+func (sc *sharded) OnEvicted(f func(string, interface{})) {
+    for _, c := range sc.shards {
+        c.OnEvicted(f)
+    }
+}
+
+func (sc *sharded) AddOnEvicted(f func(string, interface{})) {
+    for _, c := range sc.shards {
+        c.AddOnEvicted(f)
+    }
+}
+
+func (sc *sharded) OnEvictedReason(f func(string, interface{}, EvictReason)) {
+    for _, c := range sc.shards {
+        c.OnEvictedReason(f)
+    }
+}
+
+func (sc *sharded) Items() map[string]Item {
+    m := make(map[string]Item)
+    for _, c := range sc.shards {
+        for k, v := range c.Items() {
+            m[k] = v
+        }
+    }
+    return m
+}
+
+func (sc *sharded) ItemsFiltered(pred func(k string, item Item) bool) map[string]Item {
+    m := make(map[string]Item)
+    for _, c := range sc.shards {
+        for k, v := range c.ItemsFiltered(pred) {
+            m[k] = v
+        }
+    }
+    return m
+}
+
+func (sc *sharded) ItemCount() int {
+    n := 0
+    for _, c := range sc.shards {
+        n += c.ItemCount()
+    }
+    return n
+}
+
+func (sc *sharded) ApproxItemCount() int64 {
+    var n int64
+    for _, c := range sc.shards {
+        n += c.ApproxItemCount()
+    }
+    return n
+}
+
+func (sc *sharded) LiveCount() int {
+    n := 0
+    for _, c := range sc.shards {
+        n += c.LiveCount()
+    }
+    return n
+}
+
+func (sc *sharded) Flush() {
+    for _, c := range sc.shards {
+        c.Flush()
+    }
+}
+
+func (sc *sharded) FlushWithCallbacks() {
+    for _, c := range sc.shards {
+        c.FlushWithCallbacks()
+    }
+}
+
+func (sc *sharded) Stats() Stats {
+    var s Stats
+    for _, c := range sc.shards {
+        cs := c.Stats()
+        s.Hits += cs.Hits
+        s.Misses += cs.Misses
+        s.Evictions += cs.Evictions
+        s.Expirations += cs.Expirations
+        s.Dropped += cs.Dropped
+        s.GetLatency.add(cs.GetLatency)
+        s.SetLatency.add(cs.SetLatency)
+    }
+    return s
+}
+
+func (sc *sharded) ResetStats() {
+    for _, c := range sc.shards {
+        c.ResetStats()
+    }
+}
+
+// Save writes the items of every shard to w in the format of Cache.Save, so
+// either a Cache or a Sharded can load the dump. Each shard is read under its
+// own lock, so the dump isn't a snapshot of the whole cache at one instant.
+func (sc *sharded) Save(w io.Writer) error {
+    items := make(map[string]Item)
+    for _, c := range sc.shards {
+        if err := c.snapshotInto(items); err != nil {
+            return err
+        }
+    }
+    enc := gob.NewEncoder(w)
+    if err := enc.Encode(&items); err != nil {
+        return err
+    }
+    return enc.Encode(sc.shards[0].now())
+}
+
+// snapshotInto copies the cache's items to items, checking that each can be
+// saved.
+func (c *cache) snapshotInto(items map[string]Item) error {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    var err error
+    c.items.Range(func(k string, v Item) bool {
+        if err = checkGobType(k, v.Object); err != nil {
+            return false
+        }
+        items[k] = v
+        return true
+    })
+    return err
+}
+
+func (sc *sharded) SaveFile(name string) error {
+    return writeFileAtomic(name, sc.Save)
+}
+
+// Load adds the items saved by Save or Cache.Save to the shards they belong
+// to, without overwriting live items.
+func (sc *sharded) Load(r io.Reader) error {
+    dec := gob.NewDecoder(r)
+    items := map[string]Item{}
+    if err := dec.Decode(&items); err != nil {
+        return err
+    }
+    parts := make(map[*cache]map[string]Item, len(sc.shards))
+    for k, v := range items {
+        c := sc.shard(k)
+        if parts[c] == nil {
+            parts[c] = make(map[string]Item)
+        }
+        parts[c][k] = v
+    }
+    for c, part := range parts {
+        c.loadItems(part)
+    }
+    return nil
+}
+
+func (sc *sharded) LoadFile(name string) error {
+    fp, err := os.Open(name)
+    if err != nil {
+        return err
+    }
+    err = sc.Load(fp)
+    if err != nil {
+        errFile := fp.Close()
+        if errFile != nil {
+            return errFile
+        }
+        return err
+    }
+    return fp.Close()
+}
+
+// Close stops the janitor and the shards' WithPreciseExpiration timers.
+func (sc *Sharded) Close() {
+    runtime.SetFinalizer(sc, nil)
+    sc.closeJanitor()
+    for _, c := range sc.shards {
+        c.stopTimer()
+    }
+}
+
+func (sc *sharded) closeJanitor() {
+    sc.janitorMu.Lock()
+    if sc.janitor != nil {
+        sc.janitor.stop <- true
+        sc.janitor = nil
+    }
+    sc.janitorMu.Unlock()
+}
+
+func stopShardedJanitor(sc *Sharded) {
+    sc.closeJanitor()
+}
+
+// NewSharded returns a cache that spreads its keys over the given number of
+// independently locked shards. Options apply to each shard separately, so
+// WithMaxItems limits the size of every shard rather than the whole cache.
+// WithStore can't be used, as every shard would share the one store;
+// NewSharded panics if it is given.
+func NewSharded(defaultExpiration, cleanupInterval time.Duration, shards int, opts ...Option) *Sharded {
+    if shards < 1 {
+        shards = 1
+    }
+    sc := &sharded{
+        seed:   maphash.MakeSeed(),
+        shards: make([]*cache, shards),
+    }
+    for i := range sc.shards {
+        sc.shards[i] = newCache(make(map[string]Item), append([]Option{WithDefaultExpiration(defaultExpiration)}, opts...)...)
+        if _, ok := sc.shards[i].items.(mapStore); !ok {
+            panic("cache: NewSharded can't use WithStore, whose store would be shared by every shard")
+        }
+    }
+    SC := &Sharded{sc}
+    if cleanupInterval > 0 {
+        j := &janitor{
+            Interval: cleanupInterval,
+            stop:     make(chan bool),
+        }
+        sc.janitor = j
+        go j.Run(sc)
+        runtime.SetFinalizer(SC, stopShardedJanitor)
+    }
+    return SC
+}
@@ -0,0 +1,42 @@
+package cache
+
+// Snapshot returns a copy of all the cache's items, including expired items
+// not yet deleted, for Restore.
+func (c *cache) Snapshot() map[string]Item {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    return c.snapshot()
+}
+
+// Restore stores items, as returned by Snapshot, in the cache. If merge is
+// false the cache's existing items are first deleted, as by Flush; otherwise
+// they are kept unless items has the same key.
+func (c *cache) Restore(items map[string]Item, merge bool) {
+    var evicted []keyAndValue
+    c.mu.Lock()
+    if !merge {
+        c.flush()
+    }
+    for k, v := range items {
+        evicted = append(evicted, c.insert(k, v)...)
+    }
+    c.mu.Unlock()
+    c.notifyEvicted(evicted)
+}
+
+// Drain deletes all the cache's items and returns them, including expired
+// items not yet deleted but not cached misses, under one lock. Unlike
+// FlushWithCallbacks it doesn't call the eviction callbacks, as the items are
+// handed to the caller instead.
+func (c *cache) Drain() map[string]Item {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    items := c.snapshot()
+    c.flush()
+    for k, v := range items {
+        if v.Object == cachedMiss {
+            delete(items, k)
+        }
+    }
+    return items
+}
@@ -0,0 +1,177 @@
+package cache
+
+import (
+    "sync/atomic"
+    "time"
+)
+
+type Stats struct {
+    Hits        uint64
+    Misses      uint64
+    Evictions   uint64
+    Expirations uint64
+    // Dropped counts eviction events that did not fit a Subscribe channel.
+    Dropped uint64
+    // GetLatency and SetLatency are only recorded by caches created
+    // WithLatencyStats.
+    GetLatency LatencyHistogram
+    SetLatency LatencyHistogram
+}
+
+// HitRatio returns the fraction of lookups that found a live item, or 0 if
+// there were none.
+func (s Stats) HitRatio() float64 {
+    if s.Hits+s.Misses == 0 {
+        return 0
+    }
+    return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// latencyBounds are the upper bounds of the latency histograms' buckets.
+var latencyBounds = [...]time.Duration{
+    100 * time.Nanosecond,
+    250 * time.Nanosecond,
+    500 * time.Nanosecond,
+    time.Microsecond,
+    2500 * time.Nanosecond,
+    5 * time.Microsecond,
+    10 * time.Microsecond,
+    100 * time.Microsecond,
+    time.Millisecond,
+    10 * time.Millisecond,
+}
+
+// LatencyHistogram counts calls by how long they took. Counts[i] is the
+// number that took no longer than Bounds[i] and longer than Bounds[i-1]; the
+// last count, one past the end of Bounds, is for slower calls.
+type LatencyHistogram struct {
+    Bounds []time.Duration
+    Counts []uint64
+}
+
+func (h *LatencyHistogram) add(o LatencyHistogram) {
+    if o.Counts == nil {
+        return
+    }
+    if h.Counts == nil {
+        h.Bounds = o.Bounds
+        h.Counts = make([]uint64, len(o.Counts))
+    }
+    for i, n := range o.Counts {
+        h.Counts[i] += n
+    }
+}
+
+type histogram struct {
+    counts [len(latencyBounds) + 1]atomic.Uint64
+}
+
+func (h *histogram) observe(start time.Time) {
+    d := time.Since(start)
+    i := 0
+    for i < len(latencyBounds) && d > latencyBounds[i] {
+        i++
+    }
+    h.counts[i].Add(1)
+}
+
+func (h *histogram) snapshot() LatencyHistogram {
+    s := LatencyHistogram{
+        Bounds: latencyBounds[:],
+        Counts: make([]uint64, len(h.counts)),
+    }
+    for i := range h.counts {
+        s.Counts[i] = h.counts[i].Load()
+    }
+    return s
+}
+
+func (h *histogram) reset() {
+    for i := range h.counts {
+        h.counts[i].Store(0)
+    }
+}
+
+type latencyStats struct {
+    get histogram
+    set histogram
+}
+
+type stats struct {
+    hits        atomic.Uint64
+    misses      atomic.Uint64
+    evictions   atomic.Uint64
+    expirations atomic.Uint64
+    drops       atomic.Uint64
+    latency     *latencyStats
+}
+
+// The recording methods are no-ops on a nil *stats so caches created without
+// WithStats only pay for a nil check.
+
+func (s *stats) hit() {
+    if s != nil {
+        s.hits.Add(1)
+    }
+}
+
+func (s *stats) miss() {
+    if s != nil {
+        s.misses.Add(1)
+    }
+}
+
+func (s *stats) evicted() {
+    if s != nil {
+        s.evictions.Add(1)
+    }
+}
+
+func (s *stats) expired(n int) {
+    if s != nil && n > 0 {
+        s.expirations.Add(uint64(n))
+    }
+}
+
+func (s *stats) recordsLatency() bool {
+    return s != nil && s.latency != nil
+}
+
+func (s *stats) dropped() {
+    if s != nil {
+        s.drops.Add(1)
+    }
+}
+
+func (c *cache) Stats() Stats {
+    if c.stats == nil {
+        return Stats{}
+    }
+    s := Stats{
+        Hits:        c.stats.hits.Load(),
+        Misses:      c.stats.misses.Load(),
+        Evictions:   c.stats.evictions.Load(),
+        Expirations: c.stats.expirations.Load(),
+        Dropped:     c.stats.drops.Load(),
+    }
+    if c.stats.latency != nil {
+        s.GetLatency = c.stats.latency.get.snapshot()
+        s.SetLatency = c.stats.latency.set.snapshot()
+    }
+    return s
+}
+
+func (c *cache) ResetStats() {
+    if c.stats == nil {
+        return
+    }
+    c.stats.hits.Store(0)
+    c.stats.misses.Store(0)
+    c.stats.evictions.Store(0)
+    c.stats.expirations.Store(0)
+    c.stats.drops.Store(0)
+    if c.stats.latency != nil {
+        c.stats.latency.get.reset()
+        c.stats.latency.set.reset()
+    }
+}
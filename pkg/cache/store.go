@@ -0,0 +1,61 @@
+package cache
+
+// Store holds a cache's items. The cache serializes all calls to its store
+// with its own lock, so a Store needn't be safe for concurrent use, and the
+// cache doesn't modify the store from within Range.
+type Store interface {
+    Get(k string) (Item, bool)
+    Set(k string, item Item)
+    Delete(k string)
+    // Range calls f for each item until f returns false.
+    Range(f func(k string, item Item) bool)
+    Len() int
+}
+
+// mapStore is the Store caches use unless created WithStore.
+type mapStore map[string]Item
+
+func (m mapStore) Get(k string) (Item, bool) {
+    item, found := m[k]
+    return item, found
+}
+
+func (m mapStore) Set(k string, item Item) {
+    m[k] = item
+}
+
+func (m mapStore) Delete(k string) {
+    delete(m, k)
+}
+
+func (m mapStore) Range(f func(k string, item Item) bool) {
+    for k, v := range m {
+        if !f(k, v) {
+            return
+        }
+    }
+}
+
+func (m mapStore) Len() int {
+    return len(m)
+}
+
+// snapshot and storeKeys must be called with c.mu held.
+
+func (c *cache) snapshot() map[string]Item {
+    items := make(map[string]Item, c.items.Len())
+    c.items.Range(func(k string, v Item) bool {
+        items[k] = v
+        return true
+    })
+    return items
+}
+
+func (c *cache) storeKeys() []string {
+    keys := make([]string, 0, c.items.Len())
+    c.items.Range(func(k string, _ Item) bool {
+        keys = append(keys, k)
+        return true
+    })
+    return keys
+}
@@ -0,0 +1,138 @@
+package cache
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "encoding/binary"
+    "encoding/gob"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+)
+
+type streamRecord struct {
+    Key  string
+    Item Item
+}
+
+// SaveStream writes the cache's items to w as a sequence of records, each a
+// gob-encoded key and item preceded by its length as a uvarint. Unlike Save,
+// it only holds the lock to list the keys and to read each item, so writers
+// aren't blocked while the items are encoded. Items stored after the keys are
+// listed are not written. Read the records back with LoadStream.
+func (c *cache) SaveStream(w io.Writer) error {
+    return c.SaveStreamContext(context.Background(), w)
+}
+
+// SaveStreamContext is like SaveStream, but stops between records and
+// returns ctx.Err() once ctx is done.
+func (c *cache) SaveStreamContext(ctx context.Context, w io.Writer) error {
+    c.mu.RLock()
+    keys := c.storeKeys()
+    c.mu.RUnlock()
+
+    var buf bytes.Buffer
+    var length [binary.MaxVarintLen64]byte
+    for _, k := range keys {
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+        c.mu.RLock()
+        item, found := c.items.Get(k)
+        c.mu.RUnlock()
+        if !found {
+            continue
+        }
+        if err := checkGobType(k, item.Object); err != nil {
+            return err
+        }
+        buf.Reset()
+        if err := gob.NewEncoder(&buf).Encode(streamRecord{k, item}); err != nil {
+            return fmt.Errorf("encoding %s: %w", k, err)
+        }
+        n := binary.PutUvarint(length[:], uint64(buf.Len()))
+        if _, err := w.Write(length[:n]); err != nil {
+            return err
+        }
+        if _, err := w.Write(buf.Bytes()); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// LoadStream adds the items written by SaveStream as it reads them, keeping
+// any live items already in the cache as Load does. If it fails partway, the
+// items read until then stay loaded.
+func (c *cache) LoadStream(r io.Reader) error {
+    return c.LoadStreamContext(context.Background(), r)
+}
+
+// LoadStreamContext is like LoadStream, but stops between records and
+// returns ctx.Err() once ctx is done.
+func (c *cache) LoadStreamContext(ctx context.Context, r io.Reader) error {
+    br, ok := r.(io.ByteReader)
+    if !ok {
+        b := bufio.NewReader(r)
+        br, r = b, b
+    }
+    for {
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+        n, err := binary.ReadUvarint(br)
+        if err == io.EOF {
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+        // Read through a limit so a corrupt length can't make it allocate
+        // more than the stream holds
+        lr := io.LimitReader(r, int64(n))
+        var rec streamRecord
+        if err := gob.NewDecoder(lr).Decode(&rec); err != nil {
+            if errors.Is(err, io.EOF) {
+                err = io.ErrUnexpectedEOF
+            }
+            return err
+        }
+        if _, err := io.Copy(io.Discard, lr); err != nil {
+            return err
+        }
+        c.loadItems(map[string]Item{rec.Key: rec.Item})
+    }
+}
+
+// SaveFileStream writes the cache to name with SaveStreamContext. Like
+// SaveFile it writes a temporary file and renames it over name once done, so
+// a cancelled or failed save leaves any existing file as it was.
+func (c *cache) SaveFileStream(ctx context.Context, name string) error {
+    return writeFileAtomic(name, func(w io.Writer) error {
+        bw := bufio.NewWriter(w)
+        if err := c.SaveStreamContext(ctx, bw); err != nil {
+            return err
+        }
+        return bw.Flush()
+    })
+}
+
+// LoadFileStream loads a file written by SaveFileStream with
+// LoadStreamContext.
+func (c *cache) LoadFileStream(ctx context.Context, name string) error {
+    fp, err := os.Open(name)
+    if err != nil {
+        return err
+    }
+    err = c.LoadStreamContext(ctx, fp)
+    if err != nil {
+        errFile := fp.Close()
+        if errFile != nil {
+            return errFile
+        }
+        return err
+    }
+    return fp.Close()
+}
@@ -0,0 +1,49 @@
+package cache
+
+type EvictEvent struct {
+    Key    string
+    Value  interface{}
+    Reason EvictReason
+}
+
+// Subscribe returns a channel that receives an event for every item leaving
+// the cache, and a function that unsubscribes and closes the channel. Events
+// are dropped rather than block the cache when the channel's buffer is full;
+// caches created WithStats count them in Stats.Dropped.
+func (c *cache) Subscribe(buffer int) (<-chan EvictEvent, func()) {
+    ch := make(chan EvictEvent, buffer)
+    c.subMu.Lock()
+    if c.subscribers == nil {
+        c.subscribers = make(map[chan EvictEvent]struct{})
+    }
+    c.subscribers[ch] = struct{}{}
+    c.subMu.Unlock()
+    return ch, func() {
+        c.subMu.Lock()
+        if _, ok := c.subscribers[ch]; ok {
+            delete(c.subscribers, ch)
+            close(ch)
+        }
+        c.subMu.Unlock()
+    }
+}
+
+func (c *cache) hasSubscribers() bool {
+    c.subMu.Lock()
+    defer c.subMu.Unlock()
+    return len(c.subscribers) > 0
+}
+
+func (c *cache) publish(evicted []keyAndValue) {
+    c.subMu.Lock()
+    defer c.subMu.Unlock()
+    for ch := range c.subscribers {
+        for _, v := range evicted {
+            select {
+            case ch <- EvictEvent{v.key, v.value, v.reason}:
+            default:
+                c.stats.dropped()
+            }
+        }
+    }
+}
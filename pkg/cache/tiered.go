@@ -0,0 +1,58 @@
+package cache
+
+import "time"
+
+// Tiered reads from a small, fast L1 cache before falling back to a larger
+// L2 cache, and writes to both. Items are evicted from each independently.
+type Tiered struct {
+    L1        *Cache
+    L2        *Cache
+    promotion time.Duration
+}
+
+// NewTiered returns a Tiered over l1 and l2. An item found only in l2 is
+// copied into l1 to expire after promotion, which is interpreted as in Set,
+// or when it expires in l2 if that's sooner.
+func NewTiered(l1, l2 *Cache, promotion time.Duration) *Tiered {
+    return &Tiered{
+        L1:        l1,
+        L2:        l2,
+        promotion: promotion,
+    }
+}
+
+func (t *Tiered) Get(k string) (interface{}, bool) {
+    if v, found := t.L1.Get(k); found {
+        return v, true
+    }
+    v, e, found := t.L2.GetWithExpiration(k)
+    if !found {
+        return nil, false
+    }
+    d := t.promotion
+    if d == DefaultExpiration {
+        d = t.L1.DefaultExpiration()
+    }
+    if !e.IsZero() {
+        // e is on L2's clock, which need not be the wall clock
+        left := e.Sub(t.L2.clock.Now())
+        if left <= 0 {
+            return v, true
+        }
+        if d <= 0 || left < d {
+            d = left
+        }
+    }
+    t.L1.Set(k, v, d)
+    return v, true
+}
+
+func (t *Tiered) Set(k string, x interface{}, d time.Duration) {
+    t.L2.Set(k, x, d)
+    t.L1.Set(k, x, d)
+}
+
+func (t *Tiered) Delete(k string) {
+    t.L1.Delete(k)
+    t.L2.Delete(k)
+}
@@ -0,0 +1,60 @@
+package cache
+
+import "time"
+
+type Typed[T any] struct {
+    *Cache
+}
+
+func NewTyped[T any](defaultExpiration, cleanupInterval time.Duration, opts ...Option) *Typed[T] {
+    return &Typed[T]{New(defaultExpiration, cleanupInterval, opts...)}
+}
+
+func (t *Typed[T]) Set(k string, v T, d time.Duration) {
+    t.Cache.Set(k, v, d)
+}
+
+func (t *Typed[T]) Get(k string) (T, bool) {
+    // A value of another type may have been stored through the untyped Cache
+    return getAs[T](t.cache, k)
+}
+
+func (t *Typed[T]) GetWithExpiration(k string) (T, time.Time, bool) {
+    x, e, found := t.Cache.GetWithExpiration(k)
+    if !found {
+        var zero T
+        return zero, time.Time{}, false
+    }
+    v, ok := x.(T)
+    if !ok {
+        var zero T
+        return zero, time.Time{}, false
+    }
+    return v, e, true
+}
+
+func (c *cache) GetInt(k string) (int, bool) {
+    return getAs[int](c, k)
+}
+
+func (c *cache) GetString(k string) (string, bool) {
+    return getAs[string](c, k)
+}
+
+func (c *cache) GetBool(k string) (bool, bool) {
+    return getAs[bool](c, k)
+}
+
+func (c *cache) GetBytes(k string) ([]byte, bool) {
+    return getAs[[]byte](c, k)
+}
+
+func getAs[T any](c *cache, k string) (T, bool) {
+    x, found := c.Get(k)
+    v, ok := x.(T)
+    if !found || !ok {
+        var zero T
+        return zero, false
+    }
+    return v, true
+}
@@ -0,0 +1,60 @@
+package cache
+
+// WatchKey returns a channel that receives k's new value whenever it's set or
+// incremented, or nil when a miss is stored with SetMiss, and a function that
+// stops watching. Only the latest value is kept if the receiver falls
+// behind. The channel is closed once k is deleted, evicted or removed after
+// expiring, and when watching stops.
+func (c *cache) WatchKey(k string) (<-chan interface{}, func()) {
+    ch := make(chan interface{}, 1)
+    c.mu.Lock()
+    if c.watchers == nil {
+        c.watchers = make(map[string]map[chan interface{}]struct{})
+    }
+    if c.watchers[k] == nil {
+        c.watchers[k] = make(map[chan interface{}]struct{})
+    }
+    c.watchers[k][ch] = struct{}{}
+    c.mu.Unlock()
+    return ch, func() {
+        c.mu.Lock()
+        if _, ok := c.watchers[k][ch]; ok {
+            delete(c.watchers[k], ch)
+            if len(c.watchers[k]) == 0 {
+                delete(c.watchers, k)
+            }
+            close(ch)
+        }
+        c.mu.Unlock()
+    }
+}
+
+// changed and unwatch must be called with c.mu held, which also keeps sends
+// to a channel in order.
+
+func (c *cache) changed(k string, x interface{}) {
+    if x == cachedMiss {
+        x = nil
+    }
+    for ch := range c.watchers[k] {
+        // Replace a value the receiver hasn't taken yet
+        select {
+        case <-ch:
+        default:
+        }
+        ch <- x
+    }
+}
+
+func (c *cache) unwatch(k string) {
+    for ch := range c.watchers[k] {
+        close(ch)
+    }
+    delete(c.watchers, k)
+}
+
+func (c *cache) unwatchAll() {
+    for k := range c.watchers {
+        c.unwatch(k)
+    }
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+    "fmt"
+    "time"
+)
+
+// writeThrough calls the WithWriteThrough onSet hook for x, just stored under
+// k to expire after d, which is interpreted as in Set.
+func (c *cache) writeThrough(k string, x interface{}, d time.Duration) error {
+    if c.writeSet == nil {
+        return nil
+    }
+    if d == DefaultExpiration {
+        d = c.DefaultExpiration()
+    }
+    if err := c.writeSet(k, x, d); err != nil {
+        return fmt.Errorf("writing through %s: %w", k, err)
+    }
+    return nil
+}
+
+// deleteThrough calls the WithWriteThrough onDelete hook for k, just deleted.
+func (c *cache) deleteThrough(k string) error {
+    if c.writeDelete == nil {
+        return nil
+    }
+    if err := c.writeDelete(k); err != nil {
+        return fmt.Errorf("deleting %s through: %w", k, err)
+    }
+    return nil
+}
+
+// mirrorSet is writeThrough for the methods that can't return its error,
+// which is passed to the error handler instead.
+func (c *cache) mirrorSet(k string, x interface{}, d time.Duration) {
+    if err := c.writeThrough(k, x, d); err != nil {
+        c.handleError(err)
+    }
+}
+
+// mirrorItem is mirrorSet for an item stored with an expiration of its own,
+// such as one kept from the item it replaced, passing the time it has left.
+func (c *cache) mirrorItem(k string, item Item) {
+    if c.writeSet == nil {
+        return
+    }
+    d := NoExpiration
+    if item.Expiration > 0 {
+        // Not zero, which would mean the default expiration
+        d = time.Duration(item.Expiration - c.now())
+        if d <= 0 {
+            d = time.Nanosecond
+        }
+    }
+    c.mirrorSet(k, item.Object, d)
+}
+
+// mirrorDelete is deleteThrough for the methods that can't return its error,
+// which is passed to the error handler instead.
+func (c *cache) mirrorDelete(k string) {
+    if err := c.deleteThrough(k); err != nil {
+        c.handleError(err)
+    }
+}